@@ -0,0 +1,16 @@
+package cmd
+
+// The built-in tasks register themselves with the core task registry when their packages are initialized.
+// A custom handler binary can add its own tasks by importing its task packages alongside this package.
+import (
+	_ "github.com/iter8-tools/handler/tasks/bash"
+	_ "github.com/iter8-tools/handler/tasks/collect"
+	_ "github.com/iter8-tools/handler/tasks/exec"
+	_ "github.com/iter8-tools/handler/tasks/finally"
+	_ "github.com/iter8-tools/handler/tasks/ghaction"
+	_ "github.com/iter8-tools/handler/tasks/http"
+	_ "github.com/iter8-tools/handler/tasks/parallel"
+	_ "github.com/iter8-tools/handler/tasks/readiness"
+	_ "github.com/iter8-tools/handler/tasks/runscript"
+	_ "github.com/iter8-tools/handler/tasks/slack"
+)
@@ -1,10 +1,33 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/iter8-tools/etc3/api/v2alpha2"
+	"github.com/iter8-tools/handler/core"
+	"github.com/iter8-tools/handler/tasks/bash"
+	"github.com/iter8-tools/handler/tasks/collect"
+	"github.com/iter8-tools/handler/tasks/exec"
+	"github.com/iter8-tools/handler/tasks/finally"
+	"github.com/iter8-tools/handler/tasks/ghaction"
+	"github.com/iter8-tools/handler/tasks/http"
+	"github.com/iter8-tools/handler/tasks/parallel"
+	"github.com/iter8-tools/handler/tasks/readiness"
+	"github.com/iter8-tools/handler/tasks/slack"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
 )
 
 func TestInitConfig(t *testing.T) {
@@ -29,3 +52,261 @@ func TestEnv(t *testing.T) {
 	_, err = getExperimentNN()
 	assert.Error(t, err)
 }
+
+func TestBuiltinTasks(t *testing.T) {
+	for _, name := range []string{
+		bash.TaskName,
+		collect.TaskName,
+		exec.TaskName,
+		finally.TaskName,
+		ghaction.TaskName,
+		http.TaskName,
+		parallel.TaskName,
+		readiness.TaskName,
+		slack.TaskName,
+	} {
+		assert.Contains(t, core.RegisteredTasks(), name)
+	}
+
+	_, err := MakeTask(&v2alpha2.TaskSpec{
+		Task: core.StringPointer("fake/fake"),
+	})
+	assert.Error(t, err)
+}
+
+func TestRunFromFile(t *testing.T) {
+	defer func(f func(context.Context, *core.Experiment, func(*core.Experiment)) error) {
+		core.UpdateExperimentStatus = f
+	}(core.UpdateExperimentStatus)
+	defer func(f func(context.Context, *core.Experiment, map[string]string) error) {
+		core.PatchExperimentAnnotations = f
+	}(core.PatchExperimentAnnotations)
+	defer func() { file, statusFile, reportFile, action = "", "", "", "" }()
+
+	file = core.CompletePath("../", "testdata/common/bashexperiment.yaml")
+	statusFile = filepath.Join(t.TempDir(), "experiment.yaml")
+	reportFile = filepath.Join(t.TempDir(), "report.json")
+	action = "start"
+	assert.NoError(t, run(nil, nil))
+
+	data, err := ioutil.ReadFile(reportFile)
+	assert.NoError(t, err)
+	report := core.Report{}
+	assert.NoError(t, json.Unmarshal(data, &report))
+	assert.Equal(t, "start", report.Action)
+	assert.Len(t, report.Tasks, 4)
+	assert.Equal(t, bash.TaskName, report.Tasks[0].Name)
+
+	// the outcome of the action is recorded in the status file
+	exp, err := (&core.Builder{}).FromFile(statusFile).Build()
+	assert.NoError(t, err)
+	assert.True(t, exp.ActionSucceeded("start"))
+
+	file = core.CompletePath("../", "testdata/garbage.yaml")
+	assert.Error(t, run(nil, nil))
+}
+
+func TestBuildExperimentStatusFile(t *testing.T) {
+	defer func() { file, statusFile, fakeCluster = "", "", nil }()
+
+	assert.Equal(t, "dir/experiment.status.yaml", defaultStatusFile("dir/experiment.yaml"))
+
+	// the experiment file is never overwritten
+	file = core.CompletePath("../", "testdata/common/bashexperiment.yaml")
+	statusFile = file
+	_, err := buildExperiment()
+	assert.Error(t, err)
+
+	// --file and --fake-cluster cannot be used together
+	statusFile = filepath.Join(t.TempDir(), "experiment.yaml")
+	fakeCluster = []string{core.CompletePath("../", "testdata/crd")}
+	_, err = buildExperiment()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "--fake-cluster")
+}
+
+func TestValidate(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, validate(context.Background(), []string{core.CompletePath("../", "testdata/common/bashexperiment.yaml")}, &buf))
+	assert.Contains(t, buf.String(), "ok")
+
+	buf.Reset()
+	err := validate(context.Background(), []string{
+		core.CompletePath("../", "testdata/experiment7.yaml"),
+		core.CompletePath("../", "testdata/garbage.yaml"),
+	}, &buf)
+	assert.Error(t, err)
+	assert.Contains(t, buf.String(), "action start, task 0: arg 1: invalid template")
+	assert.Contains(t, buf.String(), "action start, task 1: arg 0: invalid template")
+	assert.Contains(t, buf.String(), "cannot build experiment from file")
+}
+
+func TestRunActionSignal(t *testing.T) {
+	defer func(d time.Duration) { gracePeriod, core.CommandGracePeriod = d, d }(gracePeriod)
+	gracePeriod = time.Second
+	core.CommandGracePeriod = gracePeriod
+
+	exp, err := (&core.Builder{}).FromFile(core.CompletePath("../", "testdata/common/bashexperiment.yaml")).Build()
+	assert.NoError(t, err)
+	task, err := core.MakeTask(&v2alpha2.TaskSpec{
+		Task: core.StringPointer(bash.TaskName),
+		With: map[string]apiextensionsv1.JSON{
+			"script": {Raw: []byte(`"sleep 30"`)},
+		},
+	})
+	assert.NoError(t, err)
+	report := core.NewReport(exp, "start")
+	ctx := core.ContextWithReport(context.WithValue(context.Background(), core.ContextKey("experiment"), exp), report)
+
+	defer func(f func(chan<- os.Signal) func()) { notifySignals = f }(notifySignals)
+	notifySignals = func(c chan<- os.Signal) func() {
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			c <- syscall.SIGTERM
+		}()
+		return func() {}
+	}
+	start := time.Now()
+	assert.Error(t, runAction(ctx, core.Action{task}, report))
+	assert.Less(t, int64(time.Since(start)), int64(gracePeriod))
+
+	assert.True(t, report.Interrupted)
+	assert.Len(t, report.Tasks, 1)
+	assert.True(t, report.Tasks[0].Interrupted)
+	assert.NotNil(t, report.Tasks[0].Error)
+}
+
+func TestRunActionSignalShutdownTimeout(t *testing.T) {
+	defer func(d time.Duration) { gracePeriod, core.CommandGracePeriod = d, d }(gracePeriod)
+	defer func(d time.Duration) { shutdownTimeout = d }(shutdownTimeout)
+	gracePeriod = 100 * time.Millisecond
+	core.CommandGracePeriod = gracePeriod
+	// the action is given 2.5s after the signal; finally tasks are cancelled after 0.4s
+	shutdownTimeout = writeTimeout + killDelay + 500*time.Millisecond
+
+	exp, err := (&core.Builder{}).FromFile(core.CompletePath("../", "testdata/common/bashexperiment.yaml")).Build()
+	assert.NoError(t, err)
+	task, err := core.MakeTask(&v2alpha2.TaskSpec{
+		Task: core.StringPointer("common/finally"),
+		With: map[string]apiextensionsv1.JSON{
+			"tasks": {Raw: []byte(`[{"task": "common/bash", "with": {"script": "sleep 30"}}]`)},
+		},
+	})
+	assert.NoError(t, err)
+	report := core.NewReport(exp, "start")
+	ctx := core.ContextWithReport(context.WithValue(context.Background(), core.ContextKey("experiment"), exp), report)
+
+	defer func(f func(chan<- os.Signal) func()) { notifySignals = f }(notifySignals)
+	notifySignals = func(c chan<- os.Signal) func() {
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			c <- syscall.SIGTERM
+		}()
+		return func() {}
+	}
+	start := time.Now()
+	assert.Error(t, runAction(ctx, core.Action{task}, report))
+	assert.Less(t, int64(time.Since(start)), int64(shutdownTimeout-writeTimeout))
+	assert.Len(t, report.Tasks, 1)
+	assert.NotNil(t, report.Tasks[0].Error)
+}
+
+func TestRunWithFakeCluster(t *testing.T) {
+	defer core.SetClient(nil)
+	defer func(f func() (*rest.Config, error)) { core.GetConfig = f }(core.GetConfig)
+	defer func() { fakeCluster, reportConfigMap, action = nil, "", "" }()
+	viper.AutomaticEnv()
+	os.Setenv("EXPERIMENT_NAME", "quickstart-exp")
+	os.Setenv("EXPERIMENT_NAMESPACE", core.FakeClusterNamespace)
+	defer os.Unsetenv("EXPERIMENT_NAME")
+	defer os.Unsetenv("EXPERIMENT_NAMESPACE")
+
+	fakeCluster = []string{
+		core.CompletePath("../", "testdata/crd"),
+		core.CompletePath("../", "testdata/common/bashexperiment.yaml"),
+	}
+	reportConfigMap = "start-report"
+	action = "start"
+	assert.NoError(t, run(nil, nil))
+
+	// the outcome, report and events of the action are recorded in the fake cluster
+	c, err := core.GetClient()
+	assert.NoError(t, err)
+	exp, err := (&core.Builder{}).FromCluster(&types.NamespacedName{Namespace: core.FakeClusterNamespace, Name: "quickstart-exp"}).Build()
+	assert.NoError(t, err)
+	assert.True(t, exp.ActionSucceeded("start"))
+	cm := &corev1.ConfigMap{}
+	assert.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: core.FakeClusterNamespace, Name: "start-report"}, cm))
+	assert.Contains(t, cm.Data[core.ReportKey], bash.TaskName)
+	events := &corev1.EventList{}
+	assert.NoError(t, c.List(context.Background(), events))
+	assert.NotEmpty(t, events.Items)
+
+	fakeCluster = []string{core.CompletePath("../", "testdata/does-not-exist.yaml")}
+	assert.Error(t, run(nil, nil))
+}
+
+func TestGetActionWithIncludes(t *testing.T) {
+	fragment := filepath.Join(t.TempDir(), "greet.yaml")
+	assert.NoError(t, ioutil.WriteFile(fragment, []byte(`
+- task: common/bash
+  with:
+    script: echo [[ .params.greeting ]] {{ .this.metadata.name }}
+`), 0644))
+	with, _ := json.Marshal(map[string]interface{}{
+		"file":   fragment,
+		"params": map[string]string{"greeting": "hello"},
+	})
+	include := v2alpha2.TaskSpec{Task: core.StringPointer(core.IncludeTaskName)}
+	assert.NoError(t, json.Unmarshal(with, &include.With))
+
+	exp, err := (&core.Builder{}).FromFile(core.CompletePath("../", "testdata/common/bashexperiment.yaml")).Build()
+	assert.NoError(t, err)
+	action, err := GetAction(context.Background(), exp, v2alpha2.Action{include, include})
+	assert.NoError(t, err)
+	assert.Len(t, action, 2)
+	assert.Equal(t, "echo hello {{ .this.metadata.name }}", action[1].(*bash.Task).With.Script)
+
+	// included tasks are reported with the index of the include, and their location within it
+	report := core.NewReport(exp, "start")
+	ctx := core.ContextWithReport(context.WithValue(context.Background(), core.ContextKey("experiment"), exp), report)
+	assert.NoError(t, action.Run(ctx))
+	assert.Len(t, report.Tasks, 2)
+	assert.Equal(t, 1, report.Tasks[1].Index)
+	assert.Equal(t, "include file "+fragment+", task 0", *report.Tasks[1].Include)
+
+	// includes among the tasks of groups are expanded
+	tasks, _ := json.Marshal(v2alpha2.Action{include})
+	group := v2alpha2.TaskSpec{
+		Task: core.StringPointer(finally.TaskName),
+		With: map[string]apiextensionsv1.JSON{"tasks": {Raw: tasks}},
+	}
+	action, err = GetAction(context.Background(), exp, v2alpha2.Action{group})
+	assert.NoError(t, err)
+	assert.Len(t, action, 1)
+	assert.Equal(t, bash.TaskName, *action[0].(*finally.Task).With.Tasks[0].Task)
+
+	// problems with includes are reported by validate
+	exp.Spec.Strategy.Actions["start"] = v2alpha2.Action{{
+		Task: core.StringPointer(core.IncludeTaskName),
+		With: map[string]apiextensionsv1.JSON{"file": {Raw: []byte(`"missing.yaml"`)}},
+	}}
+	var buf bytes.Buffer
+	assert.Equal(t, 1, validateExperiment(context.Background(), exp, &buf))
+	assert.Contains(t, buf.String(), "action start: include 0 (file missing.yaml)")
+
+	// problems with included tasks are reported at their location in the action spec
+	bad := filepath.Join(t.TempDir(), "bad.yaml")
+	assert.NoError(t, ioutil.WriteFile(bad, []byte("- run: echo\n- task: unknown/task\n"), 0644))
+	exp.Spec.Strategy.Actions["start"] = v2alpha2.Action{{Run: core.StringPointer("echo")}, {
+		Task: core.StringPointer(core.IncludeTaskName),
+		With: map[string]apiextensionsv1.JSON{"file": {Raw: []byte(`"` + bad + `"`)}},
+	}}
+	buf.Reset()
+	assert.Equal(t, 1, validateExperiment(context.Background(), exp, &buf))
+	assert.Contains(t, buf.String(), "action start, task 1 (include file "+bad+", task 1): ")
+	_, err = GetAction(context.Background(), exp, exp.Spec.Strategy.Actions["start"])
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "task 1 (include file "+bad+", task 1): ")
+	}
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/iter8-tools/handler/core"
 	homedir "github.com/mitchellh/go-homedir"
@@ -19,6 +20,17 @@ var log *logrus.Logger
 
 // package variables used for holding flag values
 var action string
+var timeout time.Duration
+var file string
+var statusFile string
+var dryRun bool
+var reportFile string
+var reportConfigMap string
+var metricsURL string
+var otlpEndpoint string
+var gracePeriod time.Duration
+var shutdownTimeout time.Duration
+var fakeCluster []string
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
@@ -69,4 +81,8 @@ func initConfig() {
 	if err == nil {
 		core.SetLogLevel(ll)
 	}
+
+	if err := core.LoadKubernetesRetryPolicy(viper.GetViper()); err != nil {
+		log.Warn("invalid ", core.KubernetesRetryConfigKey, " in config; using defaults: ", err)
+	}
 }
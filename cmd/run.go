@@ -3,18 +3,16 @@ package cmd
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/iter8-tools/etc3/api/v2alpha2"
 	"github.com/iter8-tools/handler/core"
-	"github.com/iter8-tools/handler/tasks/bash"
-	"github.com/iter8-tools/handler/tasks/collect"
-	"github.com/iter8-tools/handler/tasks/exec"
-	"github.com/iter8-tools/handler/tasks/ghaction"
-	"github.com/iter8-tools/handler/tasks/http"
-	"github.com/iter8-tools/handler/tasks/readiness"
-	"github.com/iter8-tools/handler/tasks/runscript"
-	"github.com/iter8-tools/handler/tasks/slack"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"k8s.io/apimachinery/pkg/types"
@@ -34,58 +32,262 @@ func getExperimentNN() (*types.NamespacedName, error) {
 	}, nil
 }
 
-// GetAction converts an action spec into an action.
-func GetAction(exp *core.Experiment, actionSpec v2alpha2.Action) (core.Action, error) {
+// GetAction converts an action spec into an action, after expanding its include specs.
+// Fragments are read from the cluster within ctx. Tasks keep their location in the action spec, with which they are reported.
+func GetAction(ctx context.Context, exp *core.Experiment, actionSpec v2alpha2.Action) (core.Action, error) {
+	// include specs are replaced by the action fragments they refer to
+	actionSpec, locations, err := core.ExpandIncludes(ctx, actionSpec)
+	if err != nil {
+		return nil, err
+	}
 	action := make(core.Action, len(actionSpec))
-	var err error
 	for i := 0; i < len(actionSpec); i++ {
-		// if this is a run ... populate runspec
-		if core.IsARun(&actionSpec[i]) {
-			if action[i], err = runscript.Make(&actionSpec[i]); err != nil {
-				break
-			}
-		} else if core.IsATask(&actionSpec[i]) {
-			if action[i], err = MakeTask(&actionSpec[i]); err != nil {
-				break
-			}
-		} else {
-			return nil, errors.New("action spec contains item that is neither run spec nor task spec")
+		if action[i], err = core.MakeTaskOrRun(&actionSpec[i]); err != nil {
+			return action, fmt.Errorf("%s: %w", locations[i], err)
 		}
+		core.SetTaskLocation(action[i], locations[i])
 	}
-	return action, err
+	return action, nil
+}
+
+// buildExperiment builds the experiment from the local file if one is specified, and from the cluster otherwise.
+// When building from a local file, experiment status updates are written to the status file instead of the cluster; the experiment file is never overwritten.
+// When a fake cluster is specified, the cluster is an in-memory one seeded from the fake cluster files.
+func buildExperiment() (*core.Experiment, error) {
+	if len(file) > 0 && len(fakeCluster) > 0 {
+		return nil, errors.New("--file and --fake-cluster cannot be used together")
+	}
+	if len(file) > 0 {
+		out := statusFile
+		if len(out) == 0 {
+			out = defaultStatusFile(file)
+		}
+		if samePath(out, file) {
+			return nil, errors.New("status file cannot be the experiment file " + file)
+		}
+		exp, err := (&core.Builder{}).FromFile(file).Build()
+		if err == nil {
+			log.Info("running against local experiment; status updates will be written to ", out)
+			core.UseExperimentFile(out)
+		}
+		return exp, err
+	}
+	if len(fakeCluster) > 0 {
+		if _, err := core.UseFakeCluster(fakeCluster...); err != nil {
+			return nil, err
+		}
+		log.Info("running against a fake cluster seeded from ", strings.Join(fakeCluster, ", "))
+	}
+	nn, err := getExperimentNN()
+	if err != nil {
+		return nil, err
+	}
+	return (&core.Builder{}).FromCluster(nn).Build()
+}
+
+// defaultStatusFile is the file, next to the experiment file, to which status updates are written by default; for example, experiment.status.yaml for experiment.yaml.
+func defaultStatusFile(file string) string {
+	ext := filepath.Ext(file)
+	return strings.TrimSuffix(file, ext) + ".status" + ext
+}
+
+// samePath determines if the two paths refer to the same file.
+func samePath(a string, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
 }
 
 // run is a helper function used in the definition of runCmd cobra command.
 func run(cmd *cobra.Command, args []string) error {
-	nn, err := getExperimentNN()
+	var exp *core.Experiment
+	if len(metricsURL) > 0 {
+		// push metrics at exit, including when the experiment cannot be fetched
+		defer func() { pushMetrics(exp) }()
+	}
+	if min := gracePeriod + killDelay + writeTimeout; shutdownTimeout <= min {
+		return fmt.Errorf("--shutdown-timeout must be longer than %v, the grace period and the time to write the report and outcome", min)
+	}
+	core.CommandGracePeriod = gracePeriod
+	if len(otlpEndpoint) > 0 {
+		shutdown, err := core.InitTracing(context.Background(), otlpEndpoint)
+		if err != nil {
+			log.Error("could not initialize tracing: ", err)
+		} else {
+			// flush spans at exit
+			defer func() {
+				if err := shutdown(context.Background()); err != nil {
+					log.Error("could not export spans: ", err)
+				}
+			}()
+		}
+	}
+	exp, err := buildExperiment()
 	if err == nil {
-		var exp *core.Experiment
-		if exp, err = (&core.Builder{}).FromCluster(nn).Build(); err == nil {
-			var actionSpec v2alpha2.Action
-			if actionSpec, err = exp.GetActionSpec(action); err == nil {
-				var action core.Action
-				if action, err = GetAction(exp, actionSpec); err == nil {
-					ctx := context.WithValue(context.Background(), core.ContextKey("experiment"), exp)
-					log.Trace("created context for experiment")
-					err = action.Run(ctx)
-					if err == nil {
-						return nil
-					}
+		var actionSpec v2alpha2.Action
+		if actionSpec, err = exp.GetActionSpec(action); err == nil {
+			report := core.NewReport(exp, action)
+			ctx := context.WithValue(context.Background(), core.ContextKey("experiment"), exp)
+			// objects read by tasks, such as secrets, are fetched once per run
+			ctx = core.ContextWithObjectCache(ctx)
+			log.Trace("created context for experiment")
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			var action core.Action
+			if action, err = GetAction(ctx, exp, actionSpec); err == nil {
+				if dryRun {
+					return action.Plan(ctx, os.Stdout)
+				}
+				ctx = contextWithEvents(core.ContextWithReport(ctx, report), exp, report.Action)
+				if len(metricsURL) > 0 {
+					ctx = core.ContextWithMetrics(ctx)
+				}
+				ctx, span := core.StartActionSpan(ctx, exp, report.Action)
+				err = runAction(ctx, action, report)
+				core.EndSpan(span, err)
+				report.End(err)
+				// the report and outcome are written even if the action timed out or was interrupted
+				wctx, wcancel := context.WithTimeout(core.WithoutCancel(ctx), writeTimeout)
+				writeReport(wctx, exp, report)
+				if rerr := core.RecordActionOutcome(wctx, exp, report.Outcome()); rerr != nil {
+					log.Error("could not record action outcome: ", rerr)
+				}
+				wcancel()
+				if err == nil {
+					return nil
 				}
-			} else {
-				log.Error("could not find specified action: " + action)
-				return nil
 			}
+		} else {
+			log.Error("could not find specified action: " + action)
+			return nil
 		}
 	}
 	return err
 }
 
+// writeTimeout is the time given to write the report and the outcome of the action, after the action ends.
+const writeTimeout = 5 * time.Second
+
+// killDelay is the time, beyond the grace period, that is allowed for killed commands to be reaped.
+const killDelay = 2 * time.Second
+
+// notifySignals relays the termination signals received by the handler to c; tests replace it to send signals without signalling the process.
+var notifySignals = func(c chan<- os.Signal) (stop func()) {
+	signal.Notify(c, syscall.SIGTERM, os.Interrupt)
+	return func() { signal.Stop(c) }
+}
+
+// runAction runs the action, and cancels it upon SIGTERM or SIGINT, for example, when the Job running the handler is deleted.
+// Commands run by tasks are sent SIGTERM, and are killed if they do not terminate within the grace period.
+// The tasks running at the time are marked as interrupted in the report.
+// Finally tasks still run after the action is cancelled, but are cancelled in turn so that the handler can write the report and outcome, and exit, within the shutdown timeout;
+// if the action has not ended by then, runAction returns without waiting for it.
+func runAction(ctx context.Context, a core.Action, report *core.Report) error {
+	shutdown, stop := context.WithCancel(context.Background())
+	defer stop()
+	ctx, cancel := context.WithCancel(core.ContextWithShutdown(ctx, shutdown))
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	defer notifySignals(signals)()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- a.Run(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case sig := <-signals:
+		log.Warn("received signal ", sig, "; cancelling action")
+		for _, tr := range report.Interrupt() {
+			log.Warn("interrupted task ", tr.Index, ": ", tr.Name)
+		}
+		cancel()
+		// the time left for the action, after which the report and outcome are written
+		actionTimeout := shutdownTimeout - writeTimeout
+		// finally tasks are cancelled early enough for their commands to terminate within the grace period
+		timer := time.AfterFunc(actionTimeout-gracePeriod-killDelay, stop)
+		defer timer.Stop()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(actionTimeout):
+			return fmt.Errorf("action did not end within shutdown timeout of %v after signal %v", shutdownTimeout, sig)
+		}
+	}
+}
+
+// contextWithEvents returns a context in which Kubernetes Events are recorded against the experiment for the lifecycle of tasks.
+// Events are not recorded when running against a local experiment file.
+func contextWithEvents(ctx context.Context, exp *core.Experiment, action string) context.Context {
+	if len(file) > 0 {
+		return ctx
+	}
+	c, err := core.GetClient()
+	if err != nil {
+		log.Warn("cannot record events: ", err)
+		return ctx
+	}
+	return core.ContextWithListener(ctx, core.NewEventRecorder(c, exp, action))
+}
+
+// pushMetrics pushes the handler metrics to the metrics URL, grouped by experiment and action.
+// Failure to push metrics is logged, and does not fail the action.
+func pushMetrics(exp *core.Experiment) {
+	grouping := map[string]string{
+		"action": action,
+	}
+	if exp != nil {
+		grouping["namespace"] = exp.Namespace
+		grouping["experiment"] = exp.Name
+	}
+	if err := core.PushMetrics(metricsURL, grouping); err != nil {
+		log.Error("could not push metrics: ", err)
+	}
+}
+
+// writeReport writes the run report to the report file and ConfigMap, if specified.
+// Failure to write the report is logged, and does not fail the action.
+func writeReport(ctx context.Context, exp *core.Experiment, report *core.Report) {
+	if len(reportFile) > 0 {
+		if err := report.ToFile(reportFile); err != nil {
+			log.Error("could not write report to file: ", err)
+		}
+	}
+	if len(reportConfigMap) > 0 {
+		nn := types.NamespacedName{
+			Namespace: exp.Namespace,
+			Name:      reportConfigMap,
+		}
+		if s := strings.Split(reportConfigMap, "/"); len(s) == 2 {
+			nn.Namespace, nn.Name = s[0], s[1]
+		}
+		if err := report.ToConfigMap(ctx, &nn); err != nil {
+			log.Error("could not write report to configmap: ", err)
+		}
+	}
+}
+
 // runCmd represents the run command
 var runCmd = &cobra.Command{
 	Use:   "run",
 	Short: "run an action",
-	Long:  `Sequentially execute all tasks in the specified action; if any task run results in an error, exit immediately with error.`,
+	Long: `Sequentially execute all tasks in the specified action; if any task run results in an error, skip the remaining tasks other than finally tasks, and exit with error.
+
+Use --dry-run to print what each task would do, with all interpolated values rendered, without executing commands, sending requests, or updating the experiment status.
+
+Upon SIGTERM or SIGINT, the action is cancelled; commands run by tasks are sent SIGTERM, and are killed if they do not terminate within --grace-period. The interrupted tasks are logged and recorded in the report. Finally tasks still run, but are cancelled in time for the handler to write the report and outcome, and exit, within --shutdown-timeout.
+
+Use --report or --report-configmap to record a JSON report of the run, with the outcome, timing, attempts, and outputs of each task.
+
+By default, the experiment is fetched from the cluster using the EXPERIMENT_NAME and EXPERIMENT_NAMESPACE environment variables. Use --file to run the action against a local experiment instead; status updates are then written to --status-file, which defaults to <file>.status.yaml next to the experiment file, rather than the cluster. Use --fake-cluster to run the action against an in-memory cluster seeded from yaml files instead.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := run(cmd, args); err != nil {
 			log.Error("Exiting with error: ", err)
@@ -98,29 +300,21 @@ func init() {
 	rootCmd.AddCommand(runCmd)
 	runCmd.PersistentFlags().StringVarP(&action, "action", "a", "", "name of the action")
 	runCmd.MarkPersistentFlagRequired("action")
+	runCmd.PersistentFlags().StringVarP(&file, "file", "f", "", "local experiment file; if specified, the experiment is not fetched from the cluster")
+	runCmd.PersistentFlags().StringSliceVar(&fakeCluster, "fake-cluster", nil, "yaml files or directories of objects, such as CRDs, experiments and secrets, with which an in-memory cluster is seeded and used instead of a real cluster; cannot be used with --file")
+	runCmd.PersistentFlags().StringVar(&statusFile, "status-file", "", "file to which experiment status updates are written when running against a local experiment; defaults to the experiment file name with a .status suffix, such as experiment.status.yaml, and cannot be the experiment file")
+	runCmd.PersistentFlags().StringVar(&reportFile, "report", "", "file to which a JSON report of the run is written")
+	runCmd.PersistentFlags().StringVar(&reportConfigMap, "report-configmap", "", "ConfigMap, as name or namespace/name, to which a JSON report of the run is written under the key "+core.ReportKey+"; the namespace defaults to that of the experiment")
+	runCmd.PersistentFlags().DurationVar(&gracePeriod, "grace-period", 10*time.Second, "time that commands run by tasks are given to terminate after the handler receives SIGTERM, before they are killed")
+	runCmd.PersistentFlags().DurationVar(&shutdownTimeout, "shutdown-timeout", 25*time.Second, "time within which the handler exits after it receives SIGTERM, including finally tasks and writing the report and outcome; keep it below the terminationGracePeriodSeconds of the pod")
+	runCmd.PersistentFlags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "URL of an OTLP/HTTP endpoint, such as http://otel-collector:4318, to which spans of the action and its tasks are exported")
+	runCmd.PersistentFlags().StringVar(&metricsURL, "metrics-url", "", "URL of a Pushgateway-compatible endpoint to which metrics are pushed at exit")
+	runCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print the plan of the action without running it")
+	runCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "maximum duration of the action, for example, 10m; no limit if unspecified")
 }
 
 // MakeTask constructs a Task from a TaskSpec or returns an error if any.
+// Tasks are looked up in the core task registry; see core.RegisterTask.
 func MakeTask(t *v2alpha2.TaskSpec) (core.Task, error) {
-	if t == nil || t.Task == nil || len(*t.Task) == 0 {
-		return nil, errors.New("nil or empty task found")
-	}
-	switch *t.Task {
-	case bash.TaskName:
-		return bash.Make(t)
-	case collect.TaskName:
-		return collect.Make(t)
-	case exec.TaskName:
-		return exec.Make(t)
-	case ghaction.TaskName:
-		return ghaction.Make(t)
-	case http.TaskName:
-		return http.Make(t)
-	case readiness.TaskName:
-		return readiness.Make(t)
-	case slack.TaskName:
-		return slack.Make(t)
-	default:
-		return nil, errors.New("unknown task: " + *t.Task)
-	}
+	return core.MakeTask(t)
 }
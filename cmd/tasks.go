@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/iter8-tools/handler/core"
+	"github.com/spf13/cobra"
+)
+
+// tasksCmd represents the tasks command
+var tasksCmd = &cobra.Command{
+	Use:   "tasks",
+	Short: "list available tasks",
+	Long:  `List the names of all tasks registered with this handler binary.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, name := range core.RegisteredTasks() {
+			fmt.Fprintln(cmd.OutOrStdout(), name)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tasksCmd)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/iter8-tools/handler/core"
+	"github.com/spf13/cobra"
+)
+
+// validateExperiment validates every task in every action of the experiment; fragments of includes are read from the cluster within ctx.
+// Each problem found is written to w, identified by its action and task index; the number of problems is returned.
+func validateExperiment(ctx context.Context, exp *core.Experiment, w io.Writer) int {
+	names := make([]string, 0, len(exp.Spec.Strategy.Actions))
+	for name := range exp.Spec.Strategy.Actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	problems := 0
+	for _, name := range names {
+		// include specs are replaced by the action fragments they refer to; included tasks are identified by the index of the include, and their location within it
+		actionSpec, locations, err := core.ExpandIncludes(ctx, exp.Spec.Strategy.Actions[name])
+		if err != nil {
+			fmt.Fprintf(w, "  action %s: %v\n", name, err)
+			problems++
+			continue
+		}
+		for i := range actionSpec {
+			report := func(err error) {
+				fmt.Fprintf(w, "  action %s, %s: %v\n", name, locations[i], err)
+				problems++
+			}
+			task, err := core.MakeTaskOrRun(&actionSpec[i])
+			if err != nil {
+				report(err)
+				continue
+			}
+			if agg := core.ValidateTask(exp, task); agg != nil {
+				for _, err := range agg.Errors() {
+					report(err)
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// validate is a helper function used in the definition of validateCmd cobra command.
+// Every experiment file is validated, and every problem is reported.
+func validate(ctx context.Context, files []string, w io.Writer) error {
+	problems := 0
+	for _, file := range files {
+		fmt.Fprintln(w, file+":")
+		exp, err := (&core.Builder{}).FromFile(file).Build()
+		if err != nil {
+			fmt.Fprintln(w, " ", err)
+			problems++
+			continue
+		}
+		n := validateExperiment(ctx, exp, w)
+		if n == 0 {
+			fmt.Fprintln(w, "  ok")
+		}
+		problems += n
+	}
+	if problems > 0 {
+		return fmt.Errorf("found %d problems", problems)
+	}
+	return nil
+}
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate FILE...",
+	Short: "validate actions in experiments",
+	Long:  `Validate all actions in the given experiment files without running them. Every task is constructed, its 'if' condition and templates are compiled, and its versionInfo is checked against the versions of the experiment. Every problem is reported with its action and task index.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return errors.New("requires at least one experiment file")
+		}
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := validate(cmd.Context(), args, cmd.OutOrStdout()); err != nil {
+			log.Error("Exiting with error: ", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
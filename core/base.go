@@ -2,9 +2,13 @@ package core
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/antonmedv/expr"
 	"github.com/iter8-tools/etc3/api/v2alpha2"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
 func init() {
@@ -30,11 +34,27 @@ func IsATask(t *v2alpha2.TaskSpec) bool {
 // Action is a slice of Tasks.
 type Action []Task
 
-// TaskMeta is common to all Tasks
+// TaskMeta is common to all Tasks.
+// Task, run and if are part of the task spec. The remaining fields are read from the task's inputs (the 'with' field of the task spec) by LoadTaskMeta; the corresponding keys are reserved for this purpose in every task.
 type TaskMeta struct {
 	Task *string `json:"task,omitempty" yaml:"task,omitempty"`
 	Run  *string `json:"run,omitempty" yaml:"run,omitempty"`
 	If   *string `json:"if,omitempty" yaml:"if,omitempty"`
+	// Retries is optional and defaulted to 0. This is the number of times a failed task is retried. Total number of attempts = 1 + Retries.
+	Retries *int32 `json:"retries,omitempty" yaml:"retries,omitempty"`
+	// Backoff is optional and determines the delay between attempts. If left unspecified, a constant delay of 1s is used.
+	Backoff *Backoff `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	// RetryOn is optional. It is a condition over the error of the failed attempt; the task is retried only if the condition evaluates to true.
+	// The condition can refer to Error (the error message) and Attempt (the number of the failed attempt).
+	RetryOn *string `json:"retryOn,omitempty" yaml:"retryOn,omitempty"`
+	// Timeout is optional. It is the maximum duration of each attempt of the task, for example, 30s or 5m.
+	Timeout *string `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	// ID is optional and identifies the task within the action. Outputs published by the task can be referenced by later tasks as tasks.<id>.outputs.<name>.
+	ID *string `json:"id,omitempty" yaml:"id,omitempty"`
+	// ContinueOnError is optional and defaulted to false. If true, a failure of this task is logged and does not fail the action.
+	ContinueOnError *bool `json:"continueOnError,omitempty" yaml:"continueOnError,omitempty"`
+	// location is the location of the task in the spec of its action, if it differs from the index of the task in the action, as for included tasks
+	location *TaskLocation
 }
 
 // GetIf returns any 'if' from TaskMeta
@@ -42,59 +62,280 @@ func (tm TaskMeta) GetIf() *string {
 	return tm.If
 }
 
+// GetTaskMeta returns the TaskMeta of a task.
+func (tm *TaskMeta) GetTaskMeta() *TaskMeta {
+	return tm
+}
+
+// metaTask is implemented by every task that embeds TaskMeta.
+type metaTask interface {
+	GetTaskMeta() *TaskMeta
+}
+
+// getTaskMeta returns the TaskMeta of a task, or nil if the task does not embed TaskMeta.
+func getTaskMeta(t Task) *TaskMeta {
+	if mt, ok := t.(metaTask); ok {
+		return mt.GetTaskMeta()
+	}
+	return nil
+}
+
+// SetTaskLocation records the location of the task in the spec of its action, such as that of an included task, for use in reports, events and logs.
+func SetTaskLocation(t Task, l TaskLocation) {
+	if tm := getTaskMeta(t); tm != nil {
+		tm.location = &l
+	}
+}
+
+// taskLocation returns the location of the task at the given index of its action in the spec of the action.
+func taskLocation(index int, t Task) TaskLocation {
+	if tm := getTaskMeta(t); tm != nil && tm.location != nil {
+		return *tm.location
+	}
+	return TaskLocation{Index: index}
+}
+
+// continuesOnError determines if a failure of the given task is tolerated.
+func continuesOnError(t Task) bool {
+	tm := getTaskMeta(t)
+	return tm != nil && tm.ContinueOnError != nil && *tm.ContinueOnError
+}
+
+// GetTaskName returns the name of a task for use in logs; runs are named "run".
+func GetTaskName(t Task) string {
+	if tm := getTaskMeta(t); tm != nil && tm.Task != nil {
+		return *tm.Task
+	}
+	return "run"
+}
+
+// LoadTaskMeta reads the fields of TaskMeta that are specified within the task's inputs, and validates them.
+func LoadTaskMeta(task Task, t *v2alpha2.TaskSpec) error {
+	tm := getTaskMeta(task)
+	if tm == nil || t == nil || len(t.With) == 0 {
+		return nil
+	}
+	jsonBytes, err := json.Marshal(t.With)
+	if err != nil {
+		return err
+	}
+	meta := TaskMeta{}
+	if err = json.Unmarshal(jsonBytes, &meta); err != nil {
+		return err
+	}
+	// task, run and if are never read from the task's inputs
+	meta.Task, meta.Run, meta.If = tm.Task, tm.Run, tm.If
+	if err = meta.validateRetryPolicy(); err != nil {
+		return err
+	}
+	if meta.ID != nil {
+		if err = validateTaskID(*meta.ID); err != nil {
+			return err
+		}
+	}
+	if meta.Timeout != nil {
+		if _, err = time.ParseDuration(*meta.Timeout); err != nil {
+			return err
+		}
+	}
+	*tm = meta
+	return nil
+}
+
 // VersionInfo contains name value pairs for each version.
 type VersionInfo struct {
 	Variables []v2alpha2.NamedValue `json:"variables,omitempty" yaml:"variables,omitempty"`
 }
 
+// FinallyTask is implemented by tasks that run even if an earlier task in the action has failed.
+type FinallyTask interface {
+	Task
+	IsFinally() bool
+}
+
+// isFinally determines if the given task runs even if an earlier task in the action has failed.
+func isFinally(t Task) bool {
+	ft, ok := t.(FinallyTask)
+	return ok && ft.IsFinally()
+}
+
+// FinallyTimeout is the time that each finally task of an action is given to run.
+// Finally tasks do not share the deadline or cancellation of the action, so that they run even if the action times out or is interrupted.
+var FinallyTimeout = 30 * time.Second
+
+// detachedContext carries the values of its parent, but not its deadline or cancellation.
+// If stop is set, the context is done when stop is done.
+type detachedContext struct {
+	parent context.Context
+	stop   context.Context
+}
+
+func (c detachedContext) Deadline() (time.Time, bool) {
+	if c.stop == nil {
+		return time.Time{}, false
+	}
+	return c.stop.Deadline()
+}
+
+func (c detachedContext) Done() <-chan struct{} {
+	if c.stop == nil {
+		return nil
+	}
+	return c.stop.Done()
+}
+
+func (c detachedContext) Err() error {
+	if c.stop == nil {
+		return nil
+	}
+	return c.stop.Err()
+}
+
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
+
+// ContextWithShutdown returns a context in which the work that outlives the cancellation of ctx, such as finally tasks and events, is cancelled when shutdown is done.
+// This bounds the time that the handler takes to exit after it is interrupted.
+func ContextWithShutdown(ctx context.Context, shutdown context.Context) context.Context {
+	return context.WithValue(ctx, ContextKey("shutdown"), shutdown)
+}
+
+// detach returns a context that carries the values of ctx, and is cancelled only by the shutdown context of ctx, if any.
+func detach(ctx context.Context) context.Context {
+	shutdown, _ := ctx.Value(ContextKey("shutdown")).(context.Context)
+	return detachedContext{parent: ctx, stop: shutdown}
+}
+
+// WithoutCancel returns a context that carries the values of ctx, such as the experiment, but not its deadline or cancellation.
+// It is used for work that must be done after the action times out or is interrupted, with a deadline of its own.
+func WithoutCancel(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
 // Run the given action.
+// The first failing task stops the action; later tasks are skipped, except for finally tasks which always run.
+// Finally tasks run on a context detached from the cancellation of ctx, within FinallyTimeout, until the shutdown context of ctx, if any, is done.
+// The returned error aggregates the errors of all failed tasks.
 func (a *Action) Run(ctx context.Context) error {
+	ctx = ContextWithOutputs(ctx)
+	var errs []error
 	for i := 0; i < len(*a); i++ {
-		log.Info("------ task starting")
-		shouldRun := true
-		exp, err := GetExperimentFromContext(ctx)
-		if err != nil {
-			return err
+		run := newTaskRun(ctx, i)
+		if len(errs) > 0 && !isFinally((*a)[i]) {
+			log.Info("------ task skipped due to earlier failure: ", taskLocation(i, (*a)[i]))
+			run.skipped(ctx, (*a)[i], SkippedByEarlierFailure)
+			_, span := startTaskSpan(ctx, (*a)[i], run)
+			span.SetAttributes(taskSkipReasonAttribute.String(SkippedByEarlierFailure))
+			span.End()
+			continue
 		}
-		// if task has a condition
-		if cond := (*a)[i].GetIf(); cond != nil {
-			// condition evaluates to false ... then shouldRun is false
-			program, err := expr.Compile(*cond, expr.Env(exp), expr.AsBool())
-			if err != nil {
-				return err
-			}
-
-			output, err := expr.Run(program, exp)
-			if err != nil {
-				return err
-			}
-
-			shouldRun = output.(bool)
+		log.Info("------ task starting")
+		taskCtx, cancel := ctx, context.CancelFunc(func() {})
+		if isFinally((*a)[i]) {
+			taskCtx, cancel = context.WithTimeout(detach(ctx), FinallyTimeout)
 		}
-		if shouldRun {
-			err := (*a)[i].Run(ctx)
-			if err != nil {
-				return err
-			}
+		if err := RunTask(contextWithTaskRun(taskCtx, run), (*a)[i]); err != nil {
+			errs = append(errs, err)
 		}
+		cancel()
 	}
-	return nil
+	return utilerrors.NewAggregate(errs)
 }
 
-// GetDefaultTags creates interpolation.Tags from experiment referenced by context
-func GetDefaultTags(ctx context.Context) *Tags {
-	tags := NewTags()
+// RunTask runs the given task if its condition, if any, evaluates to true.
+// A failed task is retried according to its retry policy.
+// If the task continues on error, its failure is logged and no error is returned.
+func RunTask(ctx context.Context, t Task) error {
+	// listeners are notified of the lifecycle of this task, if it is run by Action.Run; tasks nested within this task are not reported
+	run := getTaskRun(ctx)
+	ctx = contextWithTaskRun(ctx, nil)
+	// the span of this task is the parent of the spans of nested tasks, and its trace context is propagated in requests sent by the task
+	ctx, span := startTaskSpan(ctx, t, run)
+
+	shouldRun, err := evaluateCondition(ctx, t)
+	if err != nil {
+		run.started(ctx, t)
+		run.ended(ctx, t, 0, err)
+		EndSpan(span, err)
+		return err
+	}
+	if !shouldRun {
+		run.skipped(ctx, t, SkippedByCondition)
+		span.SetAttributes(taskSkipReasonAttribute.String(SkippedByCondition))
+		span.End()
+		return nil
+	}
+	run.started(ctx, t)
+	attempts, err := runWithRetries(contextWithTaskID(ctx, getTaskID(t)), t, run)
+	run.ended(ctx, t, attempts, err)
+	span.SetAttributes(taskAttemptsAttribute.Int(attempts))
+	EndSpan(span, err)
+	if err != nil && continuesOnError(t) {
+		log.WithField("task", GetTaskName(t)).Warn("continuing on error: ", err)
+		return nil
+	}
+	return err
+}
+
+// evaluateCondition determines if the given task should run, by evaluating its condition, if any.
+func evaluateCondition(ctx context.Context, t Task) (bool, error) {
 	exp, err := GetExperimentFromContext(ctx)
-	if err == nil {
-		obj, err := exp.ToMap()
-		if err == nil {
-			tags = tags.
-				With("this", obj).
-				WithRecommendedVersionForPromotionDeprecated(&exp.Experiment)
+	if err != nil {
+		return false, err
+	}
+	// if task has a condition
+	if cond := t.GetIf(); cond != nil {
+		// condition evaluates to false ... then shouldRun is false
+		env := getConditionEnv(ctx, exp)
+		program, err := expr.Compile(*cond, expr.Env(env), expr.AsBool())
+		if err != nil {
+			return false, err
 		}
-	} else {
+
+		output, err := expr.Run(program, env)
+		if err != nil {
+			return false, err
+		}
+
+		return output.(bool), nil
+	}
+	return true, nil
+}
+
+// getTaskID returns the id of the task, if any, which identifies the task in its context, so that it can publish outputs.
+func getTaskID(t Task) string {
+	if tm := getTaskMeta(t); tm != nil && tm.ID != nil {
+		return *tm.ID
+	}
+	return ""
+}
+
+// GetDefaultTags creates interpolation.Tags from experiment referenced by context.
+// See GetTemplateTags for the tags; if there is no experiment in the context, the tags are empty.
+func GetDefaultTags(ctx context.Context) *Tags {
+	tags, err := GetTemplateTags(ctx, nil)
+	if err != nil {
 		log.Warn("No experiment found in context")
+		empty := NewTags()
+		return &empty
 	}
+	return tags
+}
 
-	return &tags
+// runWithTimeout runs a single attempt of the task, with a deadline if the task specifies a timeout.
+func runWithTimeout(ctx context.Context, t Task) error {
+	tm := getTaskMeta(t)
+	if tm == nil || tm.Timeout == nil {
+		return t.Run(ctx)
+	}
+	timeout, err := time.ParseDuration(*tm.Timeout)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err = t.Run(ctx)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("task timed out after %s: %w", timeout, err)
+	}
+	return err
 }
@@ -106,3 +106,70 @@ func TestBadActionRun(t *testing.T) {
 	err = a.Run(ctx)
 	assert.Error(t, err)
 }
+
+type finallyTestTask struct {
+	TaskMeta
+	ran    bool
+	ctxErr error
+}
+
+func (t *finallyTestTask) Run(ctx context.Context) error {
+	t.ran = true
+	t.ctxErr = ctx.Err()
+	return errors.New("finally failed")
+}
+
+func (t *finallyTestTask) IsFinally() bool {
+	return true
+}
+
+func TestActionRunContinueOnError(t *testing.T) {
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/experiment10.yaml")).Build()
+	assert.NoError(t, err)
+	ctx := context.WithValue(context.Background(), ContextKey("experiment"), exp)
+
+	t1 := &badTestTask{
+		TaskMeta: TaskMeta{
+			ContinueOnError: BoolPointer(true),
+		},
+	}
+	t2 := &flakyTestTask{}
+	action := Action{t1, t2}
+	assert.NoError(t, action.Run(ctx))
+	assert.Equal(t, 1, t2.attempts)
+}
+
+func TestActionRunFinally(t *testing.T) {
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/experiment10.yaml")).Build()
+	assert.NoError(t, err)
+	ctx := context.WithValue(context.Background(), ContextKey("experiment"), exp)
+
+	t1 := &badTestTask{}
+	t2 := &flakyTestTask{}
+	t3 := &finallyTestTask{}
+	action := Action{t1, t2, t3}
+	err = action.Run(ctx)
+	assert.Error(t, err)
+	// errors of the failed task and the finally task are aggregated
+	assert.Contains(t, err.Error(), "shouldn't have run")
+	assert.Contains(t, err.Error(), "finally failed")
+	// tasks after the failure are skipped, except finally tasks
+	assert.Equal(t, 0, t2.attempts)
+	assert.True(t, t3.ran)
+}
+
+func TestActionRunFinallyAfterCancel(t *testing.T) {
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/experiment10.yaml")).Build()
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ContextKey("experiment"), exp))
+	cancel()
+
+	t1 := &flakyTestTask{}
+	t2 := &finallyTestTask{}
+	action := Action{t1, t2}
+	err = action.Run(ctx)
+	assert.Error(t, err)
+	// the finally task runs on a live context, with the values of the action's context
+	assert.True(t, t2.ran)
+	assert.NoError(t, t2.ctxErr)
+}
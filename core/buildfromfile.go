@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -30,3 +31,27 @@ func (b *Builder) FromFile(filePath string) *Builder {
 	b.err = errors.New("cannot build experiment from file")
 	return b
 }
+
+// ToFile writes the experiment, including its status, to a yaml file.
+// The file is created if it does not exist, and overwritten otherwise.
+func (exp *Experiment) ToFile(filePath string) error {
+	data, err := yaml.Marshal(exp)
+	if err != nil {
+		return err
+	}
+	log.Trace("writing experiment to file: ", filePath)
+	return ioutil.WriteFile(filePath, data, 0644)
+}
+
+// UseExperimentFile makes UpdateExperimentStatus and PatchExperimentAnnotations write the experiment to a yaml file instead of updating it within the cluster.
+// This enables actions to be run against a local experiment, without a cluster.
+func UseExperimentFile(filePath string) {
+	UpdateExperimentStatus = func(ctx context.Context, e *Experiment, mutate func(*Experiment)) error {
+		mutate(e)
+		return e.ToFile(filePath)
+	}
+	// annotations have already been merged into e
+	PatchExperimentAnnotations = func(ctx context.Context, e *Experiment, annotations map[string]string) error {
+		return e.ToFile(filePath)
+	}
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// objectCache holds the objects read from the cluster during an action run, such as secrets, indexed by type and namespaced name.
+// Objects are stored as JSON, so that callers cannot modify cached objects.
+type objectCache struct {
+	sync.RWMutex
+	m map[string][]byte
+}
+
+// ContextWithObjectCache returns a context in which objects read from the cluster by GetTypedObject, such as secrets, are cached.
+// Cached objects are assumed not to change during the run; for example, a secret used by several notification tasks is fetched once.
+// If ctx already holds a cache, it is returned as is.
+func ContextWithObjectCache(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(ContextKey("objectCache")).(*objectCache); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, ContextKey("objectCache"), &objectCache{
+		m: make(map[string][]byte),
+	})
+}
+
+// getObjectCache returns the object cache held by ctx, if any.
+func getObjectCache(ctx context.Context) *objectCache {
+	cache, _ := ctx.Value(ContextKey("objectCache")).(*objectCache)
+	return cache
+}
+
+// objectCacheKey returns the key of an object in the cache, which is made up of its type and namespaced name.
+func objectCacheKey(nn *client.ObjectKey, obj client.Object) string {
+	return reflect.TypeOf(obj).String() + "/" + nn.String()
+}
+
+// get copies the cached object into obj, if it is in the cache. Returns true if it is.
+func (oc *objectCache) get(nn *client.ObjectKey, obj client.Object) bool {
+	if oc == nil {
+		return false
+	}
+	oc.RLock()
+	data, ok := oc.m[objectCacheKey(nn, obj)]
+	oc.RUnlock()
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(data, obj); err != nil {
+		log.Warn("cannot read cached object: ", nn, "; ", err)
+		return false
+	}
+	log.Trace("found object in cache: ", nn)
+	return true
+}
+
+// put caches obj.
+func (oc *objectCache) put(nn *client.ObjectKey, obj client.Object) {
+	if oc == nil {
+		return
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		log.Warn("cannot cache object: ", nn, "; ", err)
+		return
+	}
+	oc.Lock()
+	defer oc.Unlock()
+	oc.m[objectCacheKey(nn, obj)] = data
+}
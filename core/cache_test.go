@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// countingClient counts the Get requests made through it.
+type countingClient struct {
+	client.Client
+	gets int
+}
+
+func (c *countingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	c.gets++
+	return c.Client.Get(ctx, key, obj)
+}
+
+func TestSetClient(t *testing.T) {
+	defer SetClient(nil)
+	c := getFakeClient(t)
+	SetClient(c)
+	rc, err := GetClient()
+	assert.NoError(t, err)
+	assert.Equal(t, c, rc)
+	// the same client is returned on every call
+	rc, err = GetClient()
+	assert.NoError(t, err)
+	assert.Equal(t, c, rc)
+}
+
+func TestObjectCache(t *testing.T) {
+	defer SetClient(nil)
+	c := &countingClient{Client: getFakeClient(t)}
+	SetClient(c)
+	assert.NoError(t, c.Create(context.Background(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "slack", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("xoxb")},
+	}))
+
+	// without a cache, every read goes to the cluster
+	for i := 0; i < 2; i++ {
+		s, err := GetSecret(context.Background(), "default/slack")
+		assert.NoError(t, err)
+		assert.Equal(t, "xoxb", string(s.Data["token"]))
+	}
+	assert.Equal(t, 2, c.gets)
+
+	// with a cache, the secret is read once
+	c.gets = 0
+	ctx := ContextWithObjectCache(context.Background())
+	assert.Equal(t, ctx, ContextWithObjectCache(ctx))
+	for i := 0; i < 3; i++ {
+		s, err := GetSecret(ctx, "default/slack")
+		assert.NoError(t, err)
+		assert.Equal(t, "xoxb", string(s.Data["token"]))
+		// modifying the returned secret does not modify the cached one
+		s.Data["token"] = []byte("modified")
+	}
+	assert.Equal(t, 1, c.gets)
+
+	// objects that are not found are not cached
+	for i := 0; i < 2; i++ {
+		_, err := GetSecret(ctx, "default/missing")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, 3, c.gets)
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// CommandGracePeriod is the time that a command is given to terminate after it is sent SIGTERM, before it is killed.
+var CommandGracePeriod = 10 * time.Second
+
+// RunCommand starts the given command and waits for it to complete.
+// The command is started in its own process group. If ctx is done before the command completes,
+// the entire process group is sent SIGTERM, and then killed if it does not terminate within CommandGracePeriod,
+// so that any child processes started by the command do not outlive it.
+func RunCommand(ctx context.Context, cmd *exec.Cmd) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		log.Warn("terminating command: ", cmd.Path, "; ", ctx.Err())
+		// a negative pid signals every process in the process group
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+		select {
+		case <-done:
+		case <-time.After(CommandGracePeriod):
+			log.Warn("killing command: ", cmd.Path, "; it did not terminate within ", CommandGracePeriod)
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			<-done
+		}
+		return ctx.Err()
+	}
+}
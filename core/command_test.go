@@ -0,0 +1,56 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCommand(t *testing.T) {
+	assert.NoError(t, RunCommand(context.Background(), exec.Command("/bin/bash", "-c", "echo hello")))
+	assert.Error(t, RunCommand(context.Background(), exec.Command("/bin/bash", "-c", "exit 1")))
+	assert.Error(t, RunCommand(context.Background(), exec.Command("/does/not/exist")))
+}
+
+func TestRunCommandTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// the background sleep would keep stdout open if the process group were not killed
+	cmd := exec.Command("/bin/bash", "-c", "sleep 30 & sleep 30")
+	cmd.Stdout = &bytes.Buffer{}
+	start := time.Now()
+	err := RunCommand(ctx, cmd)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func TestRunCommandGracePeriod(t *testing.T) {
+	defer func(d time.Duration) { CommandGracePeriod = d }(CommandGracePeriod)
+	CommandGracePeriod = 500 * time.Millisecond
+
+	// the command is given the grace period to clean up after SIGTERM
+	out := &bytes.Buffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.Command("/bin/bash", "-c", "trap 'sleep 0.1; echo cleaned up; exit 0' TERM; echo started; sleep 30 & wait")
+	cmd.Stdout = out
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		cancel()
+	}()
+	assert.Equal(t, context.Canceled, RunCommand(ctx, cmd))
+	assert.Equal(t, "started\ncleaned up\n", out.String())
+
+	// the command is killed if it ignores SIGTERM for longer than the grace period
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	cmd = exec.Command("/bin/bash", "-c", "trap '' TERM; sleep 30")
+	start := time.Now()
+	assert.Equal(t, context.DeadlineExceeded, RunCommand(ctx, cmd))
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(CommandGracePeriod))
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/iter8-tools/etc3/api/v2alpha2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// conditionHelpers returns the helper functions that are available in 'if' conditions of tasks, in addition to the fields and methods of the experiment.
+// Helpers are nil-safe: they return zero values, or nil in the case of metric, when the experiment lacks the relevant spec or status.
+// Helper names start with a lowercase letter, so that they do not shadow the exported fields and methods of the experiment.
+func conditionHelpers(exp *Experiment) map[string]interface{} {
+	return map[string]interface{}{
+		// winnerFound is true if the experiment found a winner
+		"winnerFound": func() bool {
+			return exp.WinnerFound()
+		},
+		// candidateWon is true if the experiment has a single candidate, and it is the winner
+		"candidateWon": func() bool {
+			return exp.CandidateWon()
+		},
+		// winner is the name of the winning version, or empty if there is none
+		"winner": func() string {
+			return exp.winner()
+		},
+		// stage is the stage of the experiment, or empty if it is not set
+		"stage": func() string {
+			if exp == nil || exp.Status.Stage == nil {
+				return ""
+			}
+			return string(*exp.Status.Stage)
+		},
+		// failed is true if the Failed condition of the experiment is true
+		"failed": func() bool {
+			if exp == nil {
+				return false
+			}
+			for _, c := range exp.Status.Conditions {
+				if c != nil && c.Type == v2alpha2.ExperimentConditionExperimentFailed {
+					return c.Status == corev1.ConditionTrue
+				}
+			}
+			return false
+		},
+		// versions are the names of the baseline and candidate versions
+		"versions": func() []string {
+			return exp.versionNames()
+		},
+		// variable is the value of the named variable of the version, or empty if there is none
+		"variable": func(version string, name string) string {
+			return exp.variable(version, name)
+		},
+		// metric is the value of the named metric for the version, or nil if it is not available
+		"metric": func(version string, name string) interface{} {
+			if v, ok := exp.metric(version, name); ok {
+				return v
+			}
+			return nil
+		},
+		// env is the value of the environment variable, or empty if it is not set
+		"env": os.Getenv,
+	}
+}
+
+// winner returns the name of the winning version, or empty if there is none.
+func (exp *Experiment) winner() string {
+	if exp.WinnerFound() && exp.Status.Analysis.WinnerAssessment.Data.Winner != nil {
+		return *exp.Status.Analysis.WinnerAssessment.Data.Winner
+	}
+	return ""
+}
+
+// versionNames returns the names of the baseline and candidate versions, if any.
+func (exp *Experiment) versionNames() []string {
+	names := []string{}
+	if exp == nil || exp.Spec.VersionInfo == nil {
+		return names
+	}
+	names = append(names, exp.Spec.VersionInfo.Baseline.Name)
+	for _, c := range exp.Spec.VersionInfo.Candidates {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+// variable returns the value of the named variable of the version, or empty if there is none.
+func (exp *Experiment) variable(version string, name string) string {
+	if exp == nil {
+		return ""
+	}
+	if exp.Spec.VersionInfo == nil {
+		return ""
+	}
+	vd, err := exp.GetVersionDetail(version)
+	if err != nil || vd == nil {
+		return ""
+	}
+	for _, v := range vd.Variables {
+		if v.Name == name {
+			return v.Value
+		}
+	}
+	return ""
+}
+
+// metric returns the value of the named metric for the version from the aggregated metrics in the status, if available.
+func (exp *Experiment) metric(version string, name string) (float64, bool) {
+	if exp == nil {
+		return 0, false
+	}
+	obj, err := exp.ToMap()
+	if err != nil {
+		return 0, false
+	}
+	// status.analysis.aggregatedMetrics.data.<metric>.data.<version>.value
+	var v interface{} = obj
+	for _, key := range []string{"status", "analysis", "aggregatedMetrics", "data", name, "data", version, "value"} {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return 0, false
+		}
+		if v, ok = m[key]; !ok || v == nil {
+			return 0, false
+		}
+	}
+	q, err := resource.ParseQuantity(fmt.Sprint(v))
+	if err != nil {
+		return 0, false
+	}
+	return q.AsApproximateFloat64(), true
+}
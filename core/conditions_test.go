@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/antonmedv/expr"
+	"github.com/stretchr/testify/assert"
+)
+
+// evalCondition evaluates a task condition over the experiment.
+func evalCondition(t *testing.T, exp *Experiment, cond string) interface{} {
+	env := getConditionEnv(ContextWithOutputs(context.Background()), exp)
+	out, err := expr.Eval(cond, env)
+	assert.NoError(t, err, cond)
+	return out
+}
+
+func TestConditionHelpers(t *testing.T) {
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/notification/slack1.yaml")).Build()
+	assert.NoError(t, err)
+	os.Setenv("ITER8_CONDITION_TEST", "on")
+	defer os.Unsetenv("ITER8_CONDITION_TEST")
+
+	for cond, want := range map[string]interface{}{
+		"winnerFound()":  true,
+		"candidateWon()": false,
+		"winner()":       "productpage-v1",
+		"stage()":        "Completed",
+		"failed()":       false,
+		"versions()":     []string{"productpage-v1"},
+		"variable('productpage-v1', 'namespace')":                    "bookinfo-iter8",
+		"variable('productpage-v1', 'missing')":                      "",
+		"variable('productpage-v2', 'namespace')":                    "",
+		"metric('productpage-v1', 'iter8-istio/mean-latency') < 200": true,
+		"metric('productpage-v1', 'iter8-istio/error-rate')":         float64(0),
+		"metric('productpage-v2', 'iter8-istio/error-rate') == nil":  true,
+		"metric('productpage-v1', 'missing') == nil":                 true,
+		"env('ITER8_CONDITION_TEST')":                                "on",
+		// existing conditions keep working
+		"WinnerFound() && Status.VersionRecommendedForPromotion != nil": true,
+	} {
+		assert.Equal(t, want, evalCondition(t, exp, cond), cond)
+	}
+}
+
+func TestConditionHelpersWithoutStatus(t *testing.T) {
+	exp := &Experiment{}
+	for cond, want := range map[string]interface{}{
+		"winnerFound()":                       false,
+		"candidateWon()":                      false,
+		"winner()":                            "",
+		"stage()":                             "",
+		"failed()":                            false,
+		"versions()":                          []string{},
+		"variable('v1', 'namespace')":         "",
+		"metric('v1', 'mean-latency') == nil": true,
+	} {
+		assert.Equal(t, want, evalCondition(t, exp, cond), cond)
+	}
+}
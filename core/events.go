@@ -0,0 +1,123 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	iter8 "github.com/iter8-tools/etc3/api/v2alpha2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// EventSource is the component recorded as the source of events
+	EventSource string = "iter8-handler"
+
+	// ReasonTaskStarted is the reason of events recorded when a task starts
+	ReasonTaskStarted string = "TaskStarted"
+	// ReasonTaskSkipped is the reason of events recorded when a task is skipped
+	ReasonTaskSkipped string = "TaskSkipped"
+	// ReasonTaskSucceeded is the reason of events recorded when a task succeeds
+	ReasonTaskSucceeded string = "TaskSucceeded"
+	// ReasonTaskFailed is the reason of events recorded when a task fails
+	ReasonTaskFailed string = "TaskFailed"
+	// ReasonTaskRetried is the reason of events recorded when a failed task is retried
+	ReasonTaskRetried string = "TaskRetried"
+)
+
+// EventTimeout is the time given to record each event. Events are best-effort; they are not retried.
+var EventTimeout = 5 * time.Second
+
+// EventRecorder records Kubernetes Events against the experiment for the lifecycle of the tasks in an action.
+// It implements TaskListener. Events are best-effort: failure to record an event is logged, and does not affect or delay the action.
+type EventRecorder struct {
+	client client.Client
+	exp    *Experiment
+	action string
+}
+
+// NewEventRecorder creates an event recorder for a run of the named action of the experiment.
+func NewEventRecorder(c client.Client, exp *Experiment, action string) *EventRecorder {
+	return &EventRecorder{
+		client: c,
+		exp:    exp,
+		action: action,
+	}
+}
+
+// record creates an event against the experiment. Sensitive values, such as those in the errors of tasks, are redacted from the message.
+func (er *EventRecorder) record(ctx context.Context, eventType string, reason string, message string) {
+	message = Redact(message)
+	now := metav1.NewTime(time.Now())
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: er.exp.Name + "-",
+			Namespace:    er.exp.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion:      iter8.GroupVersion.String(),
+			Kind:            "Experiment",
+			Name:            er.exp.Name,
+			Namespace:       er.exp.Namespace,
+			UID:             er.exp.UID,
+			ResourceVersion: er.exp.ResourceVersion,
+		},
+		Reason:              reason,
+		Message:             message,
+		Type:                eventType,
+		Source:              corev1.EventSource{Component: EventSource},
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+		ReportingController: EventSource,
+	}
+	log.Trace("recording event: ", reason, ": ", message)
+	// events are recorded even if ctx is done, so that failures due to cancellation are visible
+	ctx, cancel := context.WithTimeout(detach(ctx), EventTimeout)
+	defer cancel()
+	if err := er.client.Create(ctx, event); err != nil {
+		log.Warn("could not record event: ", err)
+	}
+}
+
+// describe returns a description of the task at the given index, for use in event messages.
+// Included tasks are described by their location in the spec of the action, for example, task 3 (common/bash; include file foo.yaml, task 1).
+func (er *EventRecorder) describe(index int, t Task) string {
+	l := taskLocation(index, t)
+	if len(l.Include) > 0 {
+		return fmt.Sprintf("task %d (%s; %s) in action %s", l.Index, GetTaskName(t), l.Include, er.action)
+	}
+	return fmt.Sprintf("task %d (%s) in action %s", l.Index, GetTaskName(t), er.action)
+}
+
+// TaskSkipped records a TaskSkipped event.
+func (er *EventRecorder) TaskSkipped(ctx context.Context, index int, t Task, reason string) {
+	message := er.describe(index, t) + " skipped"
+	if reason == SkippedByCondition {
+		message += "; condition is false"
+	} else if reason == SkippedByEarlierFailure {
+		message += "; an earlier task failed"
+	}
+	er.record(ctx, corev1.EventTypeNormal, ReasonTaskSkipped, message)
+}
+
+// TaskStarted records a TaskStarted event.
+func (er *EventRecorder) TaskStarted(ctx context.Context, index int, t Task) {
+	er.record(ctx, corev1.EventTypeNormal, ReasonTaskStarted, er.describe(index, t)+" started")
+}
+
+// TaskRetried records a TaskRetried event.
+func (er *EventRecorder) TaskRetried(ctx context.Context, index int, t Task, attempt int, err error) {
+	er.record(ctx, corev1.EventTypeWarning, ReasonTaskRetried, fmt.Sprintf("%s failed on attempt %d and will be retried: %v", er.describe(index, t), attempt, err))
+}
+
+// TaskEnded records a TaskSucceeded or TaskFailed event.
+func (er *EventRecorder) TaskEnded(ctx context.Context, index int, t Task, attempts int, err error) {
+	if err != nil {
+		er.record(ctx, corev1.EventTypeWarning, ReasonTaskFailed, fmt.Sprintf("%s failed after %d attempts: %v", er.describe(index, t), attempts, err))
+		return
+	}
+	er.record(ctx, corev1.EventTypeNormal, ReasonTaskSucceeded, fmt.Sprintf("%s succeeded after %d attempts", er.describe(index, t), attempts))
+}
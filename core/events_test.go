@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func getFakeClient(t *testing.T) client.Client {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+	return fake.NewClientBuilder().WithScheme(scheme).Build()
+}
+
+func TestEventRecorder(t *testing.T) {
+	c := getFakeClient(t)
+	ctx := getRetryContext(t)
+	exp, _ := GetExperimentFromContext(ctx)
+	ctx = ContextWithListener(ctx, NewEventRecorder(c, exp, "start"))
+
+	action := Action{
+		&testTask{TaskMeta: TaskMeta{Task: StringPointer("test/conditional"), If: StringPointer("WinnerFound()")}},
+		&flakyTestTask{TaskMeta: TaskMeta{Task: StringPointer("test/flaky"), Retries: Int32Pointer(1), Backoff: &Backoff{Interval: StringPointer("1ms")}}, failures: 1},
+		&badTestTask{TaskMeta: TaskMeta{Task: StringPointer("test/bad")}},
+		&testTask{TaskMeta: TaskMeta{Task: StringPointer("test/skipped")}},
+	}
+	assert.Error(t, action.Run(ctx))
+
+	events := &corev1.EventList{}
+	assert.NoError(t, c.List(context.Background(), events, client.InNamespace(exp.Namespace)))
+	reasons := map[string][]string{}
+	for _, e := range events.Items {
+		assert.Equal(t, "Experiment", e.InvolvedObject.Kind)
+		assert.Equal(t, exp.Name, e.InvolvedObject.Name)
+		assert.Equal(t, EventSource, e.Source.Component)
+		reasons[e.Reason] = append(reasons[e.Reason], e.Message)
+	}
+	assert.ElementsMatch(t, []string{
+		"task 0 (test/conditional) in action start skipped; condition is false",
+		"task 3 (test/skipped) in action start skipped; an earlier task failed",
+	}, reasons[ReasonTaskSkipped])
+	assert.Len(t, reasons[ReasonTaskStarted], 2)
+	assert.Equal(t, []string{"task 1 (test/flaky) in action start failed on attempt 1 and will be retried: connection refused"}, reasons[ReasonTaskRetried])
+	assert.Equal(t, []string{"task 1 (test/flaky) in action start succeeded after 2 attempts"}, reasons[ReasonTaskSucceeded])
+	assert.Equal(t, []string{"task 2 (test/bad) in action start failed after 1 attempts: shouldn't have run"}, reasons[ReasonTaskFailed])
+}
+
+// slowClient is a client whose creates block until their context is done.
+type slowClient struct {
+	client.Client
+	creates int
+	live    bool
+}
+
+func (c *slowClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	c.creates++
+	c.live = ctx.Err() == nil
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestEventRecorderIncludedTask(t *testing.T) {
+	er := NewEventRecorder(getFakeClient(t), &Experiment{}, "start")
+	task := &testTask{TaskMeta: TaskMeta{Task: StringPointer("test/included")}}
+	assert.Equal(t, "task 4 (test/included) in action start", er.describe(4, task))
+	SetTaskLocation(task, TaskLocation{Index: 1, Include: "include file notify.yaml, task 2"})
+	assert.Equal(t, "task 1 (test/included; include file notify.yaml, task 2) in action start", er.describe(4, task))
+}
+
+func TestEventRecorderRedacted(t *testing.T) {
+	c := getFakeClient(t)
+	ctx := getRetryContext(t)
+	exp, _ := GetExperimentFromContext(ctx)
+	er := NewEventRecorder(c, exp, "start")
+	RegisterSensitive("event-secret-value")
+
+	task := &testTask{TaskMeta: TaskMeta{Task: StringPointer("test/login")}}
+	er.TaskRetried(ctx, 0, task, 1, errors.New("login with event-secret-value refused"))
+	er.TaskEnded(ctx, 0, task, 2, errors.New("login with event-secret-value failed"))
+
+	events := &corev1.EventList{}
+	assert.NoError(t, c.List(context.Background(), events, client.InNamespace(exp.Namespace)))
+	assert.Len(t, events.Items, 2)
+	for _, e := range events.Items {
+		assert.NotContains(t, e.Message, "event-secret-value")
+		assert.Contains(t, e.Message, "login with "+RedactedValue)
+	}
+}
+
+func TestEventRecorderBestEffort(t *testing.T) {
+	defer func(d time.Duration) { EventTimeout = d }(EventTimeout)
+	EventTimeout = 10 * time.Millisecond
+	ctx := getRetryContext(t)
+	exp, _ := GetExperimentFromContext(ctx)
+	c := &slowClient{Client: getFakeClient(t)}
+	er := NewEventRecorder(c, exp, "start")
+
+	// events are recorded within EventTimeout, even if ctx is done
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	start := time.Now()
+	er.TaskStarted(ctx, 0, &testTask{TaskMeta: TaskMeta{Task: StringPointer("test/slow")}})
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.True(t, c.live)
+	// failed events are not retried
+	assert.Equal(t, 1, c.creates)
+}
@@ -46,7 +46,7 @@ func GetExperimentFromContext(ctx context.Context) (*Experiment, error) {
 }
 
 // Interpolate interpolates input arguments based on tags of the version recommended for promotion in the experiment.
-// DEPRECATED. Use tags.Interpolate in base package instead
+// DEPRECATED. Use GetTemplateTags and Tags.Interpolate instead.
 func (exp *Experiment) Interpolate(inputArgs []string) ([]string, error) {
 	var recommendedBaseline string
 	var args []string
@@ -106,7 +106,7 @@ func (exp *Experiment) WinnerFound() bool {
 
 // CandidateWon returns true if candidate won in the experiment
 func (exp *Experiment) CandidateWon() bool {
-	if exp.WinnerFound() {
+	if exp.WinnerFound() && exp.Spec.VersionInfo != nil && exp.Status.Analysis.WinnerAssessment.Data.Winner != nil {
 		if len(exp.Spec.VersionInfo.Candidates) == 1 {
 			return exp.Spec.VersionInfo.Candidates[0].Name == *exp.Status.Analysis.WinnerAssessment.Data.Winner
 		}
@@ -114,22 +114,40 @@ func (exp *Experiment) CandidateWon() bool {
 	return false
 }
 
-// GetSecret retrieves a secret from the kubernetes cluster
-func GetSecret(namespacedname string) (*corev1.Secret, error) {
-	// get secret namespace and name
-	namespace := viper.GetViper().GetString("experiment_namespace")
-	var name string
-	nn := strings.Split(namespacedname, "/")
-	if len(nn) == 1 {
-		name = nn[0]
-	} else {
-		namespace = nn[0]
-		name = nn[1]
-	}
-	log.Trace("retrieving secret: ", namespace, "/", name)
-
+// GetSecret retrieves a secret from the kubernetes cluster, and registers its data as sensitive, so that it is masked in logs.
+// The request is retried according to KubernetesRetry, until ctx is done.
+func GetSecret(ctx context.Context, namespacedname string) (*corev1.Secret, error) {
+	nn := toNamespacedName(namespacedname)
+	log.Trace("Getting secret. ", "Namespace: ", nn.Namespace, " Name: ", nn.Name)
 	secret := corev1.Secret{}
-	log.Trace("Getting secret. ", "Namespace: ", namespace, " Name: ", name)
-	err := GetTypedObject(&types.NamespacedName{Namespace: namespace, Name: name}, &secret)
+	err := GetTypedObject(ctx, &nn, &secret)
+	// the data of the secret is masked in logs
+	for _, v := range secret.Data {
+		RegisterSensitive(string(v))
+	}
 	return &secret, err
 }
+
+// GetConfigMap retrieves a ConfigMap from the kubernetes cluster.
+// The request is retried according to KubernetesRetry, until ctx is done.
+func GetConfigMap(ctx context.Context, namespacedname string) (*corev1.ConfigMap, error) {
+	nn := toNamespacedName(namespacedname)
+	log.Trace("Getting configmap. ", "Namespace: ", nn.Namespace, " Name: ", nn.Name)
+	cm := corev1.ConfigMap{}
+	err := GetTypedObject(ctx, &nn, &cm)
+	return &cm, err
+}
+
+// toNamespacedName converts a [namespace/]name string into a namespaced name.
+// The namespace defaults to the namespace of the experiment.
+func toNamespacedName(namespacedname string) types.NamespacedName {
+	nn := types.NamespacedName{Namespace: viper.GetViper().GetString("experiment_namespace")}
+	parts := strings.Split(namespacedname, "/")
+	if len(parts) == 1 {
+		nn.Name = parts[0]
+	} else {
+		nn.Namespace = parts[0]
+		nn.Name = parts[1]
+	}
+	return nn
+}
@@ -35,19 +35,11 @@ var _ = Describe("Experiment's handler field", func() {
 		It("should handle non-existing experiments properly", func() {
 			By("signaling error")
 			b := &Builder{}
-			// store k8sclient defaults
-			numAttempts := NumAttempt
-			period := Period
-			// change k8sclient defaults
-			NumAttempt = 2
-			Period = 2
+			// NotFound is not retried
 			_, err := b.FromCluster(&types.NamespacedName{
 				Name:      "non-existent",
 				Namespace: "default",
 			}).Build()
-			// change k8sclient defaults
-			NumAttempt = numAttempts
-			Period = period
 			Expect(err).To(HaveOccurred())
 		})
 
@@ -102,7 +94,7 @@ var _ = Describe("GetSecret", func() {
 			}
 			k8sClient.Create(context.Background(), &secret)
 			By("Calling GetSecret")
-			s, err := GetSecret("default/test-secret")
+			s, err := GetSecret(context.Background(), "default/test-secret")
 			Expect(err).ToNot(HaveOccurred())
 			Expect(string(s.Data["secretName"])).To(Equal("tester"))
 		})
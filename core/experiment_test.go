@@ -2,6 +2,7 @@ package core
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
 
 	"github.com/iter8-tools/etc3/api/v2alpha2"
@@ -104,3 +105,27 @@ func TestInterpolateWithExperiment(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "default revision1", v)
 }
+
+func TestUseExperimentFile(t *testing.T) {
+	defer func(f func(context.Context, *Experiment, func(*Experiment)) error) { UpdateExperimentStatus = f }(UpdateExperimentStatus)
+	defer func(f func(context.Context, *Experiment, map[string]string) error) { PatchExperimentAnnotations = f }(PatchExperimentAnnotations)
+
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/common/bashexperiment.yaml")).Build()
+	assert.NoError(t, err)
+
+	statusFile := filepath.Join(t.TempDir(), "experiment.yaml")
+	UseExperimentFile(statusFile)
+	assert.NoError(t, UpdateExperimentStatus(context.Background(), exp, func(e *Experiment) {
+		e.Status.Message = StringPointer("updated locally")
+	}))
+
+	updated, err := (&Builder{}).FromFile(statusFile).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "updated locally", *updated.Status.Message)
+	assert.Equal(t, exp.Spec.Strategy.Actions, updated.Spec.Strategy.Actions)
+
+	assert.NoError(t, RecordActionOutcome(context.Background(), exp, &ActionOutcome{Action: "start", Succeeded: true}))
+	updated, err = (&Builder{}).FromFile(statusFile).Build()
+	assert.NoError(t, err)
+	assert.True(t, updated.ActionSucceeded("start"))
+}
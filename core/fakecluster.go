@@ -0,0 +1,194 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	iter8 "github.com/iter8-tools/etc3/api/v2alpha2"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// FakeClusterNamespace is the namespace of namespaced objects that are seeded into a fake cluster without a namespace.
+const FakeClusterNamespace string = "default"
+
+// clusterScopedKinds are the built-in kinds that are not namespaced.
+var clusterScopedKinds = map[string]bool{
+	"ClusterRole":              true,
+	"ClusterRoleBinding":       true,
+	"CustomResourceDefinition": true,
+	"Namespace":                true,
+	"Node":                     true,
+	"PersistentVolume":         true,
+	"StorageClass":             true,
+}
+
+// NewFakeCluster returns a client of an in-memory cluster, seeded with the objects in the given yaml files.
+// Paths may be files, which may hold several yaml documents, or directories, whose .yaml and .yml files are read.
+// Built-in kinds, such as Secrets, ConfigMaps and Deployments, and experiments are seeded as typed objects.
+// Other kinds need to be defined by a CustomResourceDefinition among the seeded objects, such as those under testdata/crd.
+func NewFakeCluster(paths ...string) (client.Client, error) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := apiextensionsv1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	metav1.AddToGroupVersion(scheme, iter8.GroupVersion)
+	scheme.AddKnownTypes(iter8.GroupVersion, &Experiment{})
+
+	var docs []*unstructured.Unstructured
+	for _, p := range paths {
+		files, err := yamlFiles(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			d, err := readYAMLDocuments(f)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f, err)
+			}
+			docs = append(docs, d...)
+		}
+	}
+
+	// custom kinds, and whether they are namespaced, as defined by the seeded CRDs
+	customKinds := make(map[schema.GroupVersionKind]bool)
+	for _, u := range docs {
+		if u.GroupVersionKind() != apiextensionsv1.SchemeGroupVersion.WithKind("CustomResourceDefinition") {
+			continue
+		}
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, crd); err != nil {
+			return nil, fmt.Errorf("CustomResourceDefinition %s: %w", u.GetName(), err)
+		}
+		for _, v := range crd.Spec.Versions {
+			gvk := schema.GroupVersionKind{Group: crd.Spec.Group, Version: v.Name, Kind: crd.Spec.Names.Kind}
+			customKinds[gvk] = crd.Spec.Scope == apiextensionsv1.NamespaceScoped
+		}
+	}
+
+	objs := make([]client.Object, 0, len(docs))
+	for _, u := range docs {
+		gvk := u.GroupVersionKind()
+		namespaced, isCustom := customKinds[gvk]
+		if !isCustom {
+			namespaced = !clusterScopedKinds[gvk.Kind]
+		}
+		if namespaced && len(u.GetNamespace()) == 0 {
+			u.SetNamespace(FakeClusterNamespace)
+		}
+		switch {
+		case scheme.Recognizes(gvk):
+			obj, err := scheme.New(gvk)
+			if err != nil {
+				return nil, err
+			}
+			data, err := json.Marshal(u)
+			if err == nil {
+				err = json.Unmarshal(data, obj)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", gvk.Kind, u.GetName(), err)
+			}
+			if secret, ok := obj.(*corev1.Secret); ok {
+				// as the API server does, stringData is merged into data
+				for k, v := range secret.StringData {
+					if secret.Data == nil {
+						secret.Data = make(map[string][]byte)
+					}
+					secret.Data[k] = []byte(v)
+				}
+				secret.StringData = nil
+			}
+			objs = append(objs, obj.(client.Object))
+		case isCustom:
+			objs = append(objs, u)
+		default:
+			return nil, fmt.Errorf("unknown kind %s of %s; seed its CustomResourceDefinition", gvk, u.GetName())
+		}
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(), nil
+}
+
+// UseFakeCluster makes the handler use an in-memory cluster seeded with the objects in the given yaml files, instead of a real cluster.
+// Experiments, secrets, status updates, events, and reports are then read from and written to the fake cluster.
+// Returns the client of the fake cluster, so that tests can inspect it.
+func UseFakeCluster(paths ...string) (client.Client, error) {
+	c, err := NewFakeCluster(paths...)
+	if err != nil {
+		return nil, err
+	}
+	SetClient(c)
+	GetConfig = func() (*rest.Config, error) {
+		return nil, errors.New("no rest config when using a fake cluster")
+	}
+	return c, nil
+}
+
+// yamlFiles returns the path if it is a file, or the sorted .yaml and .yml files in it if it is a directory.
+func yamlFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, e := range entries {
+		if ext := filepath.Ext(e.Name()); !e.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			files = append(files, filepath.Join(path, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readYAMLDocuments reads the objects in the yaml documents of a file. Empty documents are skipped.
+func readYAMLDocuments(path string) ([]*unstructured.Unstructured, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	decoder := utilyaml.NewYAMLOrJSONDecoder(f, 4096)
+	docs := []*unstructured.Unstructured{}
+	for {
+		m := map[string]interface{}{}
+		if err := decoder.Decode(&m); err != nil {
+			if err == io.EOF {
+				return docs, nil
+			}
+			return nil, err
+		}
+		if len(m) == 0 {
+			continue
+		}
+		u := &unstructured.Unstructured{Object: m}
+		if len(u.GetKind()) == 0 || len(u.GetAPIVersion()) == 0 {
+			return nil, errors.New("object without apiVersion or kind")
+		}
+		docs = append(docs, u)
+	}
+}
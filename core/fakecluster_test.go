@@ -0,0 +1,79 @@
+package core
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+func TestUseFakeCluster(t *testing.T) {
+	defer SetClient(nil)
+	defer func(f func() (*rest.Config, error)) { GetConfig = f }(GetConfig)
+
+	c, err := UseFakeCluster(
+		CompletePath("../", "testdata/crd"),
+		CompletePath("../", "testdata/common/bashexperiment.yaml"),
+		CompletePath("../", "testdata/fakecluster/objects.yaml"),
+	)
+	assert.NoError(t, err)
+	rc, err := GetClient()
+	assert.NoError(t, err)
+	assert.Equal(t, c, rc)
+	_, err = GetConfig()
+	assert.Error(t, err)
+
+	// objects without a namespace are seeded in the default namespace
+	exp, err := (&Builder{}).FromCluster(&types.NamespacedName{Namespace: FakeClusterNamespace, Name: "quickstart-exp"}).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "bookinfo-iter8/productpage", exp.Spec.Target)
+
+	secret, err := GetSecret(context.Background(), FakeClusterNamespace+"/slack-token")
+	assert.NoError(t, err)
+	assert.Equal(t, "xoxb-fake", string(secret.Data["token"]))
+
+	cm := &corev1.ConfigMap{}
+	assert.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "bookinfo-iter8", Name: "settings"}, cm))
+	assert.Equal(t, "experiments", cm.Data["channel"])
+
+	deploy := &appsv1.Deployment{}
+	assert.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: "bookinfo-iter8", Name: "productpage-v1"}, deploy))
+	assert.Equal(t, "productpage", deploy.Spec.Template.Spec.Containers[0].Name)
+
+	// kinds defined by CRDs are seeded as unstructured objects
+	metric := &unstructured.Unstructured{}
+	metric.SetAPIVersion("iter8.tools/v2alpha2")
+	metric.SetKind("Metric")
+	assert.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: FakeClusterNamespace, Name: "request-count"}, metric))
+
+	// status updates and annotations are written to the fake cluster
+	assert.NoError(t, UpdateExperimentStatus(context.Background(), exp, func(e *Experiment) {
+		e.Status.Message = StringPointer("updated in fake cluster")
+	}))
+	assert.NoError(t, RecordActionOutcome(context.Background(), exp, &ActionOutcome{Action: "start", Succeeded: true}))
+	updated, err := (&Builder{}).FromCluster(&types.NamespacedName{Namespace: FakeClusterNamespace, Name: "quickstart-exp"}).Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "updated in fake cluster", *updated.Status.Message)
+	assert.True(t, updated.ActionSucceeded("start"))
+}
+
+func TestNewFakeClusterErrors(t *testing.T) {
+	// custom kinds need their CRD
+	_, err := NewFakeCluster(CompletePath("../", "testdata/fakecluster/objects.yaml"))
+	assert.Error(t, err)
+
+	_, err = NewFakeCluster(CompletePath("../", "testdata/does-not-exist.yaml"))
+	assert.Error(t, err)
+
+	file := filepath.Join(t.TempDir(), "bad.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("metadata:\n  name: no-kind\n"), 0644))
+	_, err = NewFakeCluster(file)
+	assert.Error(t, err)
+}
@@ -0,0 +1,355 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/ghodss/yaml"
+	"github.com/iter8-tools/etc3/api/v2alpha2"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+const (
+	// IncludeTaskName is the name of the task spec that is replaced by an action fragment from a ConfigMap or file.
+	IncludeTaskName string = "common/include"
+	// maxIncludeDepth is the maximum depth of includes within includes.
+	maxIncludeDepth int = 10
+	// includeLeftDelim and includeRightDelim delimit the parameters of an include in its fragment.
+	// They differ from those of task templates, which are left in place, to be interpolated when the tasks run.
+	includeLeftDelim  string = "[["
+	includeRightDelim string = "]]"
+	// groupTasksKey is the input of a task group that holds the specs of its tasks.
+	groupTasksKey string = "tasks"
+)
+
+// errNoValue is the error of an expression of a fragment that has no value, such as a missing parameter.
+var errNoValue = errors.New("no value")
+
+// includePlaceholder matches the placeholders that stand for the expressions of a fragment while it is parsed.
+var includePlaceholder = regexp.MustCompile(`__iter8_include_expr_([0-9]+)__`)
+
+// IncludeInputs are the inputs of an include spec.
+type IncludeInputs struct {
+	// ConfigMap is the [namespace/]name of a ConfigMap that holds fragments; the namespace defaults to that of the experiment
+	ConfigMap *string `json:"configMap,omitempty" yaml:"configMap,omitempty"`
+	// Fragment is the key of the fragment in the ConfigMap
+	Fragment *string `json:"fragment,omitempty" yaml:"fragment,omitempty"`
+	// File is the path of a file that holds a fragment, for example, when running against a local experiment
+	File *string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Params are substituted into the fragment, as [[ .params.<name> ]]
+	Params map[string]interface{} `json:"params,omitempty" yaml:"params,omitempty"`
+}
+
+// TaskLocation identifies a task of an action, after its includes are expanded, within the spec of the action.
+type TaskLocation struct {
+	// Index is the index of the task, or of the include spec through which it was included, in the spec of the action
+	Index int `json:"index" yaml:"index"`
+	// Include is the chain of includes through which the task was included, if any, for example, include file foo.yaml, task 1
+	Include string `json:"include,omitempty" yaml:"include,omitempty"`
+}
+
+// String describes the location, for example, task 3 (include file foo.yaml, task 1).
+func (l TaskLocation) String() string {
+	if len(l.Include) == 0 {
+		return fmt.Sprintf("task %d", l.Index)
+	}
+	return fmt.Sprintf("task %d (%s)", l.Index, l.Include)
+}
+
+// IsAnInclude determines if the given task spec is an include spec.
+func IsAnInclude(t *v2alpha2.TaskSpec) bool {
+	return t.Task != nil && *t.Task == IncludeTaskName
+}
+
+// ExpandIncludes replaces every include spec in the action spec by the action fragment it refers to, recursively,
+// including the include specs among the tasks of task groups, such as common/finally.
+// A fragment is a YAML list of task and run specs, in which [[ ]] expressions, such as [[ .params.<name> ]], are substituted after it is parsed,
+// so that parameters cannot change its structure. Each [[ ]] is a single expression; the template functions of the handler, such as required and quote, are available.
+// An expression that makes up a whole YAML value keeps the type of its result, for example, a number; otherwise, its result is inserted as text.
+// A parameter that is missing from the include fails the expansion, unless a function handles it, for example, [[ .params.channel | default "#iter8" ]].
+// If an include spec has an 'if' condition, it applies to every task of the fragment.
+// Returns the expanded action spec, and the location of each of its tasks in the given action spec.
+func ExpandIncludes(ctx context.Context, actionSpec v2alpha2.Action) (v2alpha2.Action, []TaskLocation, error) {
+	return expandIncludes(ctx, actionSpec, nil)
+}
+
+// expandIncludes expands the includes in actionSpec, which is a fragment included through the sources in stack.
+func expandIncludes(ctx context.Context, actionSpec v2alpha2.Action, stack []string) (v2alpha2.Action, []TaskLocation, error) {
+	expanded := make(v2alpha2.Action, 0, len(actionSpec))
+	locations := make([]TaskLocation, 0, len(actionSpec))
+	for i := range actionSpec {
+		t := &actionSpec[i]
+		if isTaskGroup(t) {
+			group, err := expandGroupIncludes(ctx, *t, stack)
+			if err != nil {
+				return nil, nil, fmt.Errorf("task %d: %w", i, err)
+			}
+			expanded = append(expanded, group)
+			locations = append(locations, TaskLocation{Index: i})
+			continue
+		}
+		if !IsAnInclude(t) {
+			expanded = append(expanded, *t)
+			locations = append(locations, TaskLocation{Index: i})
+			continue
+		}
+		inputs, err := getIncludeInputs(t)
+		if err != nil {
+			return nil, nil, fmt.Errorf("include %d: %w", i, err)
+		}
+		source := inputs.source()
+		for _, s := range stack {
+			if s == source {
+				return nil, nil, fmt.Errorf("include %d: include cycle: %s -> %s", i, strings.Join(stack, " -> "), source)
+			}
+		}
+		if len(stack) >= maxIncludeDepth {
+			return nil, nil, fmt.Errorf("include %d: includes are nested more than %d deep", i, maxIncludeDepth)
+		}
+		var fragmentLocations []TaskLocation
+		fragment, err := inputs.load(ctx)
+		if err == nil {
+			fragment, fragmentLocations, err = expandIncludes(ctx, fragment, append(append([]string{}, stack...), source))
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("include %d (%s): %w", i, source, err)
+		}
+		for _, l := range fragmentLocations {
+			include := fmt.Sprintf("include %s, task %d", source, l.Index)
+			if len(l.Include) > 0 {
+				include += ", " + l.Include
+			}
+			locations = append(locations, TaskLocation{Index: i, Include: include})
+		}
+		if t.If != nil {
+			for j := range fragment {
+				cond := "(" + *t.If + ")"
+				if fragment[j].If != nil {
+					cond += " && (" + *fragment[j].If + ")"
+				}
+				fragment[j].If = &cond
+			}
+		}
+		expanded = append(expanded, fragment...)
+	}
+	return expanded, locations, nil
+}
+
+// expandGroupIncludes returns a copy of the spec of a task group, in which the includes among its tasks are expanded.
+// Malformed tasks are left as they are, to be reported when the group is made.
+func expandGroupIncludes(ctx context.Context, t v2alpha2.TaskSpec, stack []string) (v2alpha2.TaskSpec, error) {
+	raw, ok := t.With[groupTasksKey]
+	if !ok {
+		return t, nil
+	}
+	tasks := v2alpha2.Action{}
+	if err := json.Unmarshal(raw.Raw, &tasks); err != nil {
+		return t, nil
+	}
+	tasks, _, err := expandIncludes(ctx, tasks, stack)
+	if err != nil {
+		return t, err
+	}
+	b, err := json.Marshal(tasks)
+	if err != nil {
+		return t, err
+	}
+	// the inputs are copied, so that the spec of the experiment is left unchanged
+	with := make(map[string]apiextensionsv1.JSON, len(t.With))
+	for k, v := range t.With {
+		with[k] = v
+	}
+	with[groupTasksKey] = apiextensionsv1.JSON{Raw: b}
+	t.With = with
+	return t, nil
+}
+
+// getIncludeInputs reads the inputs of an include spec, and checks that they refer to exactly one fragment.
+func getIncludeInputs(t *v2alpha2.TaskSpec) (*IncludeInputs, error) {
+	jsonBytes, err := json.Marshal(t.With)
+	if err != nil {
+		return nil, err
+	}
+	inputs := &IncludeInputs{}
+	if err := json.Unmarshal(jsonBytes, inputs); err != nil {
+		return nil, err
+	}
+	if (inputs.ConfigMap == nil) == (inputs.File == nil) {
+		return nil, errors.New("needs exactly one of configMap and file")
+	}
+	if inputs.ConfigMap != nil && inputs.Fragment == nil {
+		return nil, errors.New("needs the fragment to include from configMap " + *inputs.ConfigMap)
+	}
+	return inputs, nil
+}
+
+// source identifies the fragment of the include.
+func (inputs *IncludeInputs) source() string {
+	if inputs.ConfigMap != nil {
+		return fmt.Sprintf("configmap %s#%s", toNamespacedName(*inputs.ConfigMap), *inputs.Fragment)
+	}
+	return "file " + *inputs.File
+}
+
+// load reads the fragment of the include, parses it, and substitutes its expressions.
+func (inputs *IncludeInputs) load(ctx context.Context) (v2alpha2.Action, error) {
+	var text string
+	if inputs.ConfigMap != nil {
+		cm, err := GetConfigMap(ctx, *inputs.ConfigMap)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		if text, ok = cm.Data[*inputs.Fragment]; !ok {
+			return nil, fmt.Errorf("fragment %s not found in configmap %s", *inputs.Fragment, *inputs.ConfigMap)
+		}
+	} else {
+		b, err := ioutil.ReadFile(*inputs.File)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+	}
+	tags := NewTags().With("params", inputs.Params)
+	tags.ctx = ctx
+	text, exprs, err := tags.evaluateExpressions(text)
+	if err != nil {
+		return nil, err
+	}
+	var parsed interface{}
+	if err := yaml.Unmarshal([]byte(text), &parsed); err != nil {
+		return nil, fmt.Errorf("fragment is not a list of task specs: %w", err)
+	}
+	b, err := json.Marshal(substituteExpressions(parsed, exprs))
+	if err != nil {
+		return nil, err
+	}
+	fragment := v2alpha2.Action{}
+	if err := json.Unmarshal(b, &fragment); err != nil {
+		return nil, fmt.Errorf("fragment is not a list of task specs: %w", err)
+	}
+	return fragment, nil
+}
+
+// includeExpr is the result of a [[ ]] expression of a fragment.
+type includeExpr struct {
+	// text is the result as text
+	text string
+	// value is the result with its type, as decoded from JSON
+	value interface{}
+}
+
+// evaluateExpressions evaluates the [[ ]] expressions of text with tags, and replaces them by placeholders.
+// Returns the text with placeholders, and the result of each expression by the index of its placeholder.
+func (tags *Tags) evaluateExpressions(text string) (string, []includeExpr, error) {
+	templ, err := tags.parseTemplate(text, includeLeftDelim, includeRightDelim)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid fragment: %w", err)
+	}
+	if templ.Tree == nil {
+		return text, nil, nil
+	}
+	// unlike in task templates, a missing value is an error
+	templ.Funcs(template.FuncMap{printValueFunc: func(v interface{}) (interface{}, error) {
+		if v == nil {
+			return nil, errNoValue
+		}
+		return v, nil
+	}})
+	var out strings.Builder
+	exprs := []includeExpr{}
+	root := templ.Tree.Root
+	nodes := root.Nodes
+	defer func() { root.Nodes = nodes }()
+	for _, node := range nodes {
+		switch n := node.(type) {
+		case *parse.TextNode:
+			out.Write(n.Text)
+		case *parse.ActionNode:
+			if len(n.Pipe.Decl) > 0 {
+				return "", nil, fmt.Errorf("invalid fragment: variables are not supported in %s", n)
+			}
+			var expr includeExpr
+			// the expression is evaluated alone, and then with its result encoded as JSON, to keep its type
+			var buf bytes.Buffer
+			root.Nodes = []parse.Node{n}
+			if err := templ.Execute(&buf, tags.M); err != nil {
+				if errors.Is(err, errNoValue) {
+					return "", nil, fmt.Errorf("invalid fragment: %s has no value", printedPipe(n))
+				}
+				return "", nil, fmt.Errorf("cannot interpolate string: %w", err)
+			}
+			expr.text = buf.String()
+			asJSON := n.Copy().(*parse.ActionNode)
+			asJSON.Pipe.Cmds = append(asJSON.Pipe.Cmds, &parse.CommandNode{
+				NodeType: parse.NodeCommand,
+				Pos:      n.Pos,
+				Args:     []parse.Node{parse.NewIdentifier("toJson").SetTree(templ.Tree).SetPos(n.Pos)},
+			})
+			buf.Reset()
+			root.Nodes = []parse.Node{asJSON}
+			if err := templ.Execute(&buf, tags.M); err != nil {
+				return "", nil, fmt.Errorf("cannot interpolate string: %w", err)
+			}
+			if err := json.Unmarshal(buf.Bytes(), &expr.value); err != nil {
+				expr.value = expr.text
+			}
+			fmt.Fprintf(&out, "__iter8_include_expr_%d__", len(exprs))
+			exprs = append(exprs, expr)
+		default:
+			return "", nil, fmt.Errorf("invalid fragment: only [[ ]] expressions are supported, not %s", n)
+		}
+	}
+	return out.String(), exprs, nil
+}
+
+// printedPipe returns the text of the pipeline of n, without the print value function that parseTemplate appended to it.
+func printedPipe(n *parse.ActionNode) string {
+	pipe := n.Pipe.CopyPipe()
+	pipe.Cmds = pipe.Cmds[:len(pipe.Cmds)-1]
+	return includeLeftDelim + " " + pipe.String() + " " + includeRightDelim
+}
+
+// substituteExpressions replaces the placeholders in the strings of the parsed fragment v by the results of the expressions.
+// A string that is a placeholder is replaced by the result with its type; placeholders within strings are replaced by the results as text.
+func substituteExpressions(v interface{}, exprs []includeExpr) interface{} {
+	switch val := v.(type) {
+	case string:
+		if m := includePlaceholder.FindStringSubmatch(val); m != nil && m[0] == val {
+			i, _ := strconv.Atoi(m[1])
+			return exprs[i].value
+		}
+		return substituteText(val, exprs)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, e := range val {
+			out[substituteText(k, exprs)] = substituteExpressions(e, exprs)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, e := range val {
+			out[i] = substituteExpressions(e, exprs)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// substituteText replaces the placeholders in s by the results of the expressions as text.
+func substituteText(s string, exprs []includeExpr) string {
+	return includePlaceholder.ReplaceAllStringFunc(s, func(p string) string {
+		i, _ := strconv.Atoi(includePlaceholder.FindStringSubmatch(p)[1])
+		return exprs[i].text
+	})
+}
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/iter8-tools/etc3/api/v2alpha2"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// includeSpec returns an include spec with the given inputs.
+func includeSpec(t *testing.T, inputs IncludeInputs) v2alpha2.TaskSpec {
+	data, err := json.Marshal(inputs)
+	assert.NoError(t, err)
+	with := map[string]apiextensionsv1.JSON{}
+	assert.NoError(t, json.Unmarshal(data, &with))
+	return v2alpha2.TaskSpec{Task: StringPointer(IncludeTaskName), With: with}
+}
+
+func TestExpandIncludes(t *testing.T) {
+	defer SetClient(nil)
+	c := getFakeClient(t)
+	SetClient(c)
+	file := filepath.Join(t.TempDir(), "notify.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(`
+- task: notification/slack
+  with:
+    channel: [[ .params.channel ]]
+    icon: [[ .params.icon | default "rocket" ]]
+`), 0644))
+	assert.NoError(t, c.Create(context.Background(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "fragments", Namespace: "iter8"},
+		Data: map[string]string{
+			"promote": `
+- run: kubectl apply -f [[ required "manifest is required" .params.manifest ]]
+- task: common/bash
+  if: WinnerFound()
+  with:
+    script: echo {{ .this.metadata.name }}
+- task: common/include
+  with:
+    file: ` + file + `
+    params:
+      channel: [[ .params.channel ]]
+`,
+			"cycle-a": `
+- task: common/include
+  with:
+    configMap: iter8/fragments
+    fragment: cycle-b
+`,
+			"cycle-b": `
+- task: common/include
+  with:
+    configMap: iter8/fragments
+    fragment: cycle-a
+`,
+			"garbage": "task: common/bash",
+			"missing-param": `
+- run: echo [[ .params.missing ]]
+`,
+			"control": `
+[[ if .params.text ]]- run: echo[[ end ]]
+`,
+		},
+	}))
+
+	include := includeSpec(t, IncludeInputs{
+		ConfigMap: StringPointer("iter8/fragments"),
+		Fragment:  StringPointer("promote"),
+		Params:    map[string]interface{}{"manifest": "promote.yaml", "channel": "C123"},
+	})
+	include.If = StringPointer("CandidateWon()")
+	actionSpec, locations, err := ExpandIncludes(context.Background(), v2alpha2.Action{
+		{Task: StringPointer("common/readiness")},
+		include,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, actionSpec, 4)
+	// included tasks are located by the index of the include, and their location within it
+	assert.Equal(t, []string{
+		"task 0",
+		"task 1 (include configmap iter8/fragments#promote, task 0)",
+		"task 1 (include configmap iter8/fragments#promote, task 1)",
+		"task 1 (include configmap iter8/fragments#promote, task 2, include file " + file + ", task 0)",
+	}, []string{locations[0].String(), locations[1].String(), locations[2].String(), locations[3].String()})
+	assert.Equal(t, "common/readiness", *actionSpec[0].Task)
+	assert.Nil(t, actionSpec[0].If)
+	// parameters are interpolated, and templates of tasks are left in place
+	assert.Equal(t, "kubectl apply -f promote.yaml", *actionSpec[1].Run)
+	assert.Equal(t, `"echo {{ .this.metadata.name }}"`, string(actionSpec[2].With["script"].Raw))
+	assert.Equal(t, `"C123"`, string(actionSpec[3].With["channel"].Raw))
+	assert.Equal(t, `"rocket"`, string(actionSpec[3].With["icon"].Raw))
+	// the condition of the include applies to every included task
+	assert.Equal(t, "(CandidateWon())", *actionSpec[1].If)
+	assert.Equal(t, "(CandidateWon()) && (WinnerFound())", *actionSpec[2].If)
+	assert.Equal(t, "(CandidateWon())", *actionSpec[3].If)
+
+	for inputs, msg := range map[*IncludeInputs]string{
+		{ConfigMap: StringPointer("iter8/fragments"), Fragment: StringPointer("promote")}: "manifest is required",
+		{ConfigMap: StringPointer("iter8/fragments"), Fragment: StringPointer("cycle-a")}: "include cycle: configmap iter8/fragments#cycle-a -> configmap iter8/fragments#cycle-b -> configmap iter8/fragments#cycle-a",
+		{ConfigMap: StringPointer("iter8/fragments"), Fragment: StringPointer("missing")}: "fragment missing not found in configmap iter8/fragments",
+		{ConfigMap: StringPointer("iter8/fragments"), Fragment: StringPointer("garbage")}: "fragment is not a list of task specs",
+		// parameters that are missing from the include are errors
+		{ConfigMap: StringPointer("iter8/fragments"), Fragment: StringPointer("missing-param")}: "[[ .params.missing ]] has no value",
+		{ConfigMap: StringPointer("iter8/fragments"), Fragment: StringPointer("control")}:       "only [[ ]] expressions are supported",
+		{ConfigMap: StringPointer("iter8/missing"), Fragment: StringPointer("promote")}:         "not found",
+		{ConfigMap: StringPointer("iter8/fragments")}:                                           "needs the fragment to include",
+		{ConfigMap: StringPointer("iter8/fragments"), File: StringPointer(file)}:                "needs exactly one of configMap and file",
+		{File: StringPointer(filepath.Join(t.TempDir(), "missing.yaml"))}:                       "no such file",
+	} {
+		_, _, err := ExpandIncludes(context.Background(), v2alpha2.Action{includeSpec(t, *inputs)})
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), msg)
+			assert.Contains(t, err.Error(), "include 0")
+		}
+	}
+}
+
+func TestExpandIncludesParams(t *testing.T) {
+	defer SetClient(nil)
+	c := getFakeClient(t)
+	SetClient(c)
+	assert.NoError(t, c.Create(context.Background(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "fragments", Namespace: "iter8"},
+		Data: map[string]string{
+			"params": `
+- task: common/exec
+  with:
+    cmd: echo
+    args: [[ .params.args ]]
+    comment: '[[ .params.text ]]'
+    replicas: [[ .params.replicas ]]
+    message: hello [[ .params.text ]]
+`,
+		},
+	}))
+
+	// parameters are substituted after the fragment is parsed, so that they cannot change its structure
+	text := "a: b # c 'single' \"double\"\n- task: common/bash"
+	actionSpec, _, err := ExpandIncludes(context.Background(), v2alpha2.Action{includeSpec(t, IncludeInputs{
+		ConfigMap: StringPointer("iter8/fragments"),
+		Fragment:  StringPointer("params"),
+		Params:    map[string]interface{}{"text": text, "replicas": 3, "args": []string{"x", "y"}},
+	})})
+	assert.NoError(t, err)
+	assert.Len(t, actionSpec, 1)
+	quoted, _ := json.Marshal(text)
+	assert.Equal(t, string(quoted), string(actionSpec[0].With["comment"].Raw))
+	quoted, _ = json.Marshal("hello " + text)
+	assert.Equal(t, string(quoted), string(actionSpec[0].With["message"].Raw))
+	// whole values keep their types
+	assert.Equal(t, "3", string(actionSpec[0].With["replicas"].Raw))
+	assert.Equal(t, `["x","y"]`, string(actionSpec[0].With["args"].Raw))
+}
+
+func TestExpandIncludesInGroups(t *testing.T) {
+	MustRegisterTaskGroup("test/group", makeTestTask)
+	file := filepath.Join(t.TempDir(), "notify.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(`
+- run: echo [[ .params.greeting ]]
+- run: echo bye
+`), 0644))
+	include := includeSpec(t, IncludeInputs{File: StringPointer(file), Params: map[string]interface{}{"greeting": "hello"}})
+	tasks, err := json.Marshal(v2alpha2.Action{include, {Run: StringPointer("echo done")}})
+	assert.NoError(t, err)
+	group := v2alpha2.TaskSpec{
+		Task: StringPointer("test/group"),
+		With: map[string]apiextensionsv1.JSON{"tasks": {Raw: tasks}},
+	}
+
+	actionSpec, _, err := ExpandIncludes(context.Background(), v2alpha2.Action{group})
+	assert.NoError(t, err)
+	assert.Len(t, actionSpec, 1)
+	expanded := v2alpha2.Action{}
+	assert.NoError(t, json.Unmarshal(actionSpec[0].With["tasks"].Raw, &expanded))
+	assert.Len(t, expanded, 3)
+	assert.Equal(t, "echo hello", *expanded[0].Run)
+	assert.Equal(t, "echo done", *expanded[2].Run)
+	// the spec of the group is left unchanged
+	assert.Equal(t, string(tasks), string(group.With["tasks"].Raw))
+
+	// problems with includes in groups are reported with the index of the group
+	missing, err := json.Marshal(v2alpha2.Action{includeSpec(t, IncludeInputs{File: StringPointer(file)})})
+	assert.NoError(t, err)
+	group.With = map[string]apiextensionsv1.JSON{"tasks": {Raw: missing}}
+	_, _, err = ExpandIncludes(context.Background(), v2alpha2.Action{{Run: StringPointer("echo")}, group})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "task 1: include 0")
+		assert.Contains(t, err.Error(), "[[ .params.greeting ]] has no value")
+	}
+}
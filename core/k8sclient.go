@@ -3,7 +3,7 @@ package core
 import (
 	"context"
 	"errors"
-	"time"
+	"sync"
 
 	iter8 "github.com/iter8-tools/etc3/api/v2alpha2"
 	corev1 "k8s.io/api/core/v1"
@@ -11,6 +11,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 )
@@ -28,15 +29,38 @@ var GetConfig = func() (*rest.Config, error) {
 	return config.GetConfig()
 }
 
-// NumAttempt is the number of times to attempt Get operation for a k8s resource
-var NumAttempt = 10
+// sharedClient holds the K8s client shared by the handler, once it is constructed.
+var sharedClient = struct {
+	sync.Mutex
+	c client.Client
+}{}
+
+// GetClient returns the K8s client shared by the handler. The client is constructed using NewClient upon first use,
+// and reused thereafter, so that the scheme and client are built once per process.
+// GetClient variable is useful for test mocks; alternatively, use SetClient to inject a client.
+var GetClient = func() (client.Client, error) {
+	sharedClient.Lock()
+	defer sharedClient.Unlock()
+	if sharedClient.c == nil {
+		c, err := NewClient()
+		if err != nil {
+			return nil, err
+		}
+		sharedClient.c = c
+	}
+	return sharedClient.c, nil
+}
 
-// Period is the time duration between between each attempt
-var Period = 18 * time.Second
+// SetClient sets the K8s client returned by GetClient. If c is nil, a new client is constructed upon next use.
+func SetClient(c client.Client) {
+	sharedClient.Lock()
+	defer sharedClient.Unlock()
+	sharedClient.c = c
+}
 
-// GetClient constructs and returns a K8s client.
+// NewClient constructs and returns a K8s client.
 // The returned client has experiment.Experiment type registered.
-var GetClient = func() (rc client.Client, err error) {
+func NewClient() (rc client.Client, err error) {
 	var restConf *rest.Config
 	restConf, err = GetConfig()
 	if err != nil {
@@ -56,6 +80,12 @@ var GetClient = func() (rc client.Client, err error) {
 		metav1.AddToGroupVersion(scheme, gv)
 		scheme.AddKnownTypes(gv, &corev1.Secret{})
 
+		// Support for run reports
+		scheme.AddKnownTypes(gv, &corev1.ConfigMap{})
+
+		// Support for task lifecycle events
+		scheme.AddKnownTypes(gv, &corev1.Event{})
+
 		return nil
 	}
 
@@ -81,7 +111,7 @@ func (b *Builder) FromCluster(nn *client.ObjectKey) *Builder {
 		Experiment: *iter8.NewExperiment(nn.Name, nn.Namespace).Build(),
 	}
 	var err error
-	if err = GetTypedObject(nn, exp); err == nil {
+	if err = GetTypedObject(context.Background(), nn, exp); err == nil {
 		b.exp = exp
 		return b
 	}
@@ -90,39 +120,77 @@ func (b *Builder) FromCluster(nn *client.ObjectKey) *Builder {
 	return b
 }
 
-// GetTypedObject gets a typed object from the k8s cluster. Types of such objects include experiment, knative service, etc. This function attempts to get the object `numAttempts` times, with the interval between attempts equal to `period`.
-func GetTypedObject(nn *client.ObjectKey, obj client.Object) error {
+// GetTypedObject gets a typed object from the k8s cluster. Types of such objects include experiment, knative service, etc.
+// The request is retried according to KubernetesRetry, until ctx is done.
+// If ctx holds an object cache, the object is read from the cluster only if it is not already in the cache.
+func GetTypedObject(ctx context.Context, nn *client.ObjectKey, obj client.Object) error {
 	log.Trace("Getting typed object: ", obj.GetObjectKind())
-	var err error
-	var rc client.Client
-	if rc, err = GetClient(); err == nil {
-		for i := 0; i < NumAttempt; i++ {
-			err = rc.Get(context.Background(), *nn, obj)
-			if err == nil {
-				break
-			}
-			log.Trace("Finished attempt: ", i, " Total attempts: ", NumAttempt)
-			time.Sleep(Period)
-			log.Trace("Sleeping period: ", Period)
-		}
+	cache := getObjectCache(ctx)
+	if cache.get(nn, obj) {
+		return nil
 	}
-	return err
+	rc, err := GetClient()
+	if err != nil {
+		return err
+	}
+	if err = withKubernetesRetries(ctx, func(ctx context.Context) error {
+		return rc.Get(ctx, *nn, obj)
+	}); err != nil {
+		return err
+	}
+	cache.put(nn, obj)
+	return nil
 }
 
 // UpdateInClusterExperiment updates the experiment within cluster.
-func UpdateInClusterExperiment(e *Experiment) (err error) {
+// The request is retried according to KubernetesRetry, until ctx is done.
+func UpdateInClusterExperiment(ctx context.Context, e *Experiment) (err error) {
 	var c client.Client
 	if c, err = GetClient(); err == nil {
-		err = c.Update(context.Background(), e)
+		err = withKubernetesRetries(ctx, func(ctx context.Context) error {
+			return c.Update(ctx, e)
+		})
 	}
 	return err
 }
 
-// UpdateInClusterExperimentStatus updates the experiment status within cluster.
-func UpdateInClusterExperimentStatus(e *Experiment) (err error) {
-	var c client.Client
-	if c, err = GetClient(); err == nil {
-		err = c.Status().Update(context.Background(), e)
+// UpdateExperimentStatus applies mutate to the status of the experiment, and persists the status fields that it changes.
+// By default, the status is patched within the cluster; see UseExperimentFile for running without a cluster.
+// Tasks should use this variable instead of calling UpdateInClusterExperimentStatus directly.
+var UpdateExperimentStatus = UpdateInClusterExperimentStatus
+
+// UpdateInClusterExperimentStatus applies mutate to the status of the experiment, and patches the status within cluster.
+// The merge patch holds only the fields changed by mutate, so that status fields owned by others, such as etc3, are not overwritten.
+// If the experiment has changed in the cluster since it was read, the patch conflicts; the experiment is then re-read,
+// and mutate is applied again to the latest version. Upon success, e holds the latest version of the experiment.
+// Conflicts and failed requests are retried until ctx is done.
+func UpdateInClusterExperimentStatus(ctx context.Context, e *Experiment, mutate func(*Experiment)) error {
+	c, err := GetClient()
+	if err != nil {
+		return err
 	}
-	return err
+	latest := e
+	attempt := 0
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if attempt++; attempt > 1 {
+			log.Warn("experiment status update conflicted; re-reading experiment")
+			latest = &Experiment{}
+			if err := GetTypedObject(ctx, &client.ObjectKey{Namespace: e.Namespace, Name: e.Name}, latest); err != nil {
+				return err
+			}
+		}
+		original := &Experiment{Experiment: *latest.Experiment.DeepCopyObject().(*iter8.Experiment)}
+		mutate(latest)
+		patch := client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})
+		return withKubernetesRetries(ctx, func(ctx context.Context) error {
+			return c.Status().Patch(ctx, latest, patch)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if latest != e {
+		*e = *latest
+	}
+	return nil
 }
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	iter8 "github.com/iter8-tools/etc3/api/v2alpha2"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestUpdateInClusterExperimentStatus(t *testing.T) {
+	scheme := runtime.NewScheme()
+	metav1.AddToGroupVersion(scheme, iter8.GroupVersion)
+	scheme.AddKnownTypes(iter8.GroupVersion, &Experiment{})
+
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/experiment10.yaml")).Build()
+	assert.NoError(t, err)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(exp).Build()
+	defer SetClient(nil)
+	SetClient(c)
+	nn := types.NamespacedName{Namespace: exp.Namespace, Name: exp.Name}
+
+	// the experiment is read by the handler ...
+	stale := &Experiment{}
+	assert.NoError(t, c.Get(context.Background(), nn, stale))
+	// ... and then its status is changed by someone else
+	inCluster := &Experiment{}
+	assert.NoError(t, c.Get(context.Background(), nn, inCluster))
+	inCluster.Status.Message = StringPointer("updated by etc3")
+	assert.NoError(t, c.Status().Update(context.Background(), inCluster))
+
+	// the update conflicts, and is applied again to the latest experiment
+	mutations := 0
+	assert.NoError(t, UpdateInClusterExperimentStatus(context.Background(), stale, func(e *Experiment) {
+		mutations++
+		e.SetAggregatedBuiltinHists(v1.JSON{Raw: []byte(`{"version": "v1"}`)})
+	}))
+	assert.Equal(t, 2, mutations)
+	assert.Equal(t, "updated by etc3", *stale.Status.Message)
+
+	// changes by others are not overwritten
+	assert.NoError(t, c.Get(context.Background(), nn, inCluster))
+	assert.Equal(t, "updated by etc3", *inCluster.Status.Message)
+	assert.JSONEq(t, `{"version": "v1"}`, string(inCluster.Status.Analysis.AggregatedBuiltinHists.Data.Raw))
+
+	// failures are returned to the caller
+	assert.NoError(t, c.Delete(context.Background(), inCluster))
+	assert.Error(t, UpdateInClusterExperimentStatus(context.Background(), stale, func(e *Experiment) {
+		e.Status.Message = StringPointer("updated by handler")
+	}))
+}
+
+// unavailableClient is a client whose requests fail as if the API server were unavailable.
+type unavailableClient struct {
+	client.Client
+}
+
+func (c *unavailableClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	return apierrors.NewServiceUnavailable("unavailable")
+}
+
+func (c *unavailableClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return apierrors.NewServiceUnavailable("unavailable")
+}
+
+func TestWritesHonorContext(t *testing.T) {
+	defer func(p KubernetesRetryPolicy) { KubernetesRetry = p }(KubernetesRetry)
+	KubernetesRetry = KubernetesRetryPolicy{
+		Retries: 10,
+		Backoff: Backoff{Interval: StringPointer("1h")},
+	}
+	defer SetClient(nil)
+	SetClient(&unavailableClient{Client: getFakeClient(t)})
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/experiment10.yaml")).Build()
+	assert.NoError(t, err)
+
+	// retries stop when the context of the caller is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Error(t, PatchInClusterExperimentAnnotations(ctx, exp, map[string]string{"a": "b"}))
+	assert.Error(t, NewReport(exp, "start").ToConfigMap(ctx, &types.NamespacedName{Namespace: "default", Name: "report"}))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
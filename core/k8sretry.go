@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/spf13/viper"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+// KubernetesRetryConfigKey is the key of the Kubernetes retry policy in the handler config file.
+const KubernetesRetryConfigKey string = "kubernetes_retry"
+
+// KubernetesRetryPolicy determines how failed Kubernetes API requests made by the handler are retried.
+// Only requests that fail with retryable errors, such as timeouts, throttling, server errors, and connection failures, are retried.
+type KubernetesRetryPolicy struct {
+	// Retries is the maximum number of retries of a failed request
+	Retries int `json:"retries" yaml:"retries"`
+	// Backoff determines the delay between attempts
+	Backoff Backoff `json:"backoff" yaml:"backoff"`
+}
+
+// DefaultKubernetesRetryPolicy returns the default Kubernetes retry policy:
+// up to 5 retries, with exponential backoff from 1s to 30s, and a jitter of 0.2.
+func DefaultKubernetesRetryPolicy() KubernetesRetryPolicy {
+	return KubernetesRetryPolicy{
+		Retries: 5,
+		Backoff: Backoff{
+			Type:        StringPointer(ExponentialBackoff),
+			Interval:    StringPointer("1s"),
+			MaxInterval: StringPointer("30s"),
+			Jitter:      Float64Pointer(0.2),
+		},
+	}
+}
+
+// KubernetesRetry is the retry policy of Kubernetes API requests made by the handler.
+var KubernetesRetry = DefaultKubernetesRetryPolicy()
+
+// LoadKubernetesRetryPolicy sets KubernetesRetry from the kubernetes_retry key of the handler config, if any.
+// Settings that are absent from the config keep their default values.
+func LoadKubernetesRetryPolicy(v *viper.Viper) error {
+	policy := DefaultKubernetesRetryPolicy()
+	if !v.IsSet(KubernetesRetryConfigKey) {
+		KubernetesRetry = policy
+		return nil
+	}
+	if err := v.UnmarshalKey(KubernetesRetryConfigKey, &policy); err != nil {
+		return err
+	}
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	KubernetesRetry = policy
+	return nil
+}
+
+// validate validates the retries and backoff of the policy.
+func (p *KubernetesRetryPolicy) validate() error {
+	if p.Retries < 0 {
+		return errors.New("retries cannot be negative")
+	}
+	return p.Backoff.validate()
+}
+
+// isRetryableAPIError determines if a failed Kubernetes API request may succeed if retried.
+// Errors such as NotFound, Forbidden and Invalid are not retryable.
+func isRetryableAPIError(err error) bool {
+	if apierrors.IsTimeout(err) || apierrors.IsServerTimeout(err) || apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err) || apierrors.IsInternalError(err) || apierrors.IsUnexpectedServerError(err) {
+		return true
+	}
+	if utilnet.IsConnectionRefused(err) || utilnet.IsConnectionReset(err) || utilnet.IsProbableEOF(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// withKubernetesRetries makes a Kubernetes API request, and retries it according to KubernetesRetry if it fails with a retryable error.
+// Retries stop when ctx is done; the error of the last attempt is returned.
+func withKubernetesRetries(ctx context.Context, request func(context.Context) error) error {
+	policy := KubernetesRetry
+	for attempt := 1; ; attempt++ {
+		err := request(ctx)
+		if err == nil {
+			return nil
+		}
+		if !isRetryableAPIError(err) {
+			log.Trace("Kubernetes API request failed; not retrying: ", err)
+			return err
+		}
+		if attempt > policy.Retries {
+			log.Warn("Kubernetes API request failed after ", attempt, " attempts: ", err)
+			return err
+		}
+		delay := policy.Backoff.delay(attempt)
+		log.Warn("Kubernetes API request failed; retrying after ", delay, ": ", err)
+		kubernetesAPIRetries.Inc()
+		if Sleep(ctx, delay) != nil {
+			log.Warn("context done; not retrying Kubernetes API request")
+			return err
+		}
+	}
+}
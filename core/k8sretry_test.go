@@ -0,0 +1,118 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestIsRetryableAPIError(t *testing.T) {
+	gr := schema.GroupResource{Resource: "experiments"}
+	for _, err := range []error{
+		apierrors.NewServiceUnavailable("unavailable"),
+		apierrors.NewTooManyRequests("throttled", 1),
+		apierrors.NewInternalError(errors.New("internal")),
+		apierrors.NewTimeoutError("timeout", 1),
+		apierrors.NewServerTimeout(gr, "get", 1),
+		&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+	} {
+		assert.True(t, isRetryableAPIError(err), err.Error())
+	}
+	for _, err := range []error{
+		apierrors.NewNotFound(gr, "name"),
+		apierrors.NewForbidden(gr, "name", errors.New("forbidden")),
+		apierrors.NewBadRequest("bad"),
+		apierrors.NewConflict(gr, "name", errors.New("conflict")),
+	} {
+		assert.False(t, isRetryableAPIError(err), err.Error())
+	}
+}
+
+func TestWithKubernetesRetries(t *testing.T) {
+	defer func(p KubernetesRetryPolicy) { KubernetesRetry = p }(KubernetesRetry)
+	KubernetesRetry = KubernetesRetryPolicy{
+		Retries: 2,
+		Backoff: Backoff{Interval: StringPointer("1ms")},
+	}
+
+	// retryable errors are retried until the request succeeds
+	attempts := 0
+	assert.NoError(t, withKubernetesRetries(context.Background(), func(ctx context.Context) error {
+		attempts++
+		if attempts < 2 {
+			return apierrors.NewServiceUnavailable("unavailable")
+		}
+		return nil
+	}))
+	assert.Equal(t, 2, attempts)
+
+	// or until retries are exhausted
+	attempts = 0
+	assert.True(t, apierrors.IsServiceUnavailable(withKubernetesRetries(context.Background(), func(ctx context.Context) error {
+		attempts++
+		return apierrors.NewServiceUnavailable("unavailable")
+	})))
+	assert.Equal(t, 3, attempts)
+
+	// other errors are not retried
+	attempts = 0
+	assert.True(t, apierrors.IsNotFound(withKubernetesRetries(context.Background(), func(ctx context.Context) error {
+		attempts++
+		return apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, "name")
+	})))
+	assert.Equal(t, 1, attempts)
+
+	// retries stop when the context is done
+	KubernetesRetry.Backoff.Interval = StringPointer("1h")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	attempts = 0
+	start := time.Now()
+	assert.Error(t, withKubernetesRetries(ctx, func(ctx context.Context) error {
+		attempts++
+		return apierrors.NewServiceUnavailable("unavailable")
+	}))
+	assert.Equal(t, 1, attempts)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestLoadKubernetesRetryPolicy(t *testing.T) {
+	defer func(p KubernetesRetryPolicy) { KubernetesRetry = p }(KubernetesRetry)
+
+	v := viper.New()
+	assert.NoError(t, LoadKubernetesRetryPolicy(v))
+	assert.Equal(t, DefaultKubernetesRetryPolicy(), KubernetesRetry)
+
+	v.SetConfigType("yaml")
+	assert.NoError(t, v.ReadConfig(bytes.NewBufferString(`
+kubernetes_retry:
+  retries: 3
+  backoff:
+    interval: 200ms
+    maxInterval: 2s
+`)))
+	assert.NoError(t, LoadKubernetesRetryPolicy(v))
+	assert.Equal(t, 3, KubernetesRetry.Retries)
+	assert.Equal(t, "200ms", *KubernetesRetry.Backoff.Interval)
+	assert.Equal(t, "2s", *KubernetesRetry.Backoff.MaxInterval)
+	// unspecified settings keep their defaults
+	assert.Equal(t, ExponentialBackoff, *KubernetesRetry.Backoff.Type)
+	assert.Equal(t, 0.2, *KubernetesRetry.Backoff.Jitter)
+
+	assert.NoError(t, v.ReadConfig(bytes.NewBufferString(`
+kubernetes_retry:
+  retries: -1
+`)))
+	assert.Error(t, LoadKubernetesRetryPolicy(v))
+	assert.Equal(t, 3, KubernetesRetry.Retries)
+}
@@ -0,0 +1,92 @@
+package core
+
+import "context"
+
+// TaskListener is notified of the lifecycle of the tasks in an action.
+// Only tasks run directly by Action.Run are reported; tasks nested within task groups are not.
+// The index identifies the task within the action.
+type TaskListener interface {
+	// TaskSkipped is called when a task is not run, either because its condition is false or because an earlier task failed
+	TaskSkipped(ctx context.Context, index int, t Task, reason string)
+	// TaskStarted is called before the first attempt of a task
+	TaskStarted(ctx context.Context, index int, t Task)
+	// TaskRetried is called when a failed attempt of a task is about to be retried
+	TaskRetried(ctx context.Context, index int, t Task, attempt int, err error)
+	// TaskEnded is called after the last attempt of a task, with the error of the task, if any
+	TaskEnded(ctx context.Context, index int, t Task, attempts int, err error)
+}
+
+// ContextWithListener returns a context in which Action.Run notifies l, in addition to any listeners already in ctx.
+func ContextWithListener(ctx context.Context, l TaskListener) context.Context {
+	listeners := getListeners(ctx)
+	return context.WithValue(ctx, ContextKey("listeners"), append(listeners[:len(listeners):len(listeners)], l))
+}
+
+// getListeners returns the listeners held by ctx, if any.
+func getListeners(ctx context.Context) []TaskListener {
+	listeners, _ := ctx.Value(ContextKey("listeners")).([]TaskListener)
+	return listeners
+}
+
+// taskRun identifies a task run by Action.Run, and notifies the listeners of its lifecycle.
+// All methods are no-ops on a nil taskRun.
+type taskRun struct {
+	index     int
+	listeners []TaskListener
+}
+
+// newTaskRun returns a taskRun for the task at the given index, which notifies the listeners held by ctx, if any.
+func newTaskRun(ctx context.Context, index int) *taskRun {
+	return &taskRun{
+		index:     index,
+		listeners: getListeners(ctx),
+	}
+}
+
+// contextWithTaskRun returns a context that holds the given task run.
+// A nil run stops notification, for example, for tasks nested within a task group.
+func contextWithTaskRun(ctx context.Context, run *taskRun) context.Context {
+	return context.WithValue(ctx, ContextKey("taskRun"), run)
+}
+
+// getTaskRun returns the task run held by ctx, if any.
+func getTaskRun(ctx context.Context) *taskRun {
+	run, _ := ctx.Value(ContextKey("taskRun")).(*taskRun)
+	return run
+}
+
+func (run *taskRun) skipped(ctx context.Context, t Task, reason string) {
+	if run == nil {
+		return
+	}
+	for _, l := range run.listeners {
+		l.TaskSkipped(ctx, run.index, t, reason)
+	}
+}
+
+func (run *taskRun) started(ctx context.Context, t Task) {
+	if run == nil {
+		return
+	}
+	for _, l := range run.listeners {
+		l.TaskStarted(ctx, run.index, t)
+	}
+}
+
+func (run *taskRun) retried(ctx context.Context, t Task, attempt int, err error) {
+	if run == nil {
+		return
+	}
+	for _, l := range run.listeners {
+		l.TaskRetried(ctx, run.index, t, attempt, err)
+	}
+}
+
+func (run *taskRun) ended(ctx context.Context, t Task, attempts int, err error) {
+	if run == nil {
+		return
+	}
+	for _, l := range run.listeners {
+		l.TaskEnded(ctx, run.index, t, attempts, err)
+	}
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// MetricsJob is the job under which metrics are pushed.
+const MetricsJob string = "iter8-handler"
+
+// handler metrics; these are registered in metricsRegistry, and not in the global Prometheus registry
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	taskDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "handler_task_duration_seconds",
+		Help:    "Duration of tasks, including retries.",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 14),
+	}, []string{"task"})
+
+	taskFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "handler_task_failures_total",
+		Help: "Number of failed tasks.",
+	}, []string{"task"})
+
+	collectRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "handler_collect_requests_total",
+		Help: "Number of requests sent by the metrics/collect task, by version and response code.",
+	}, []string{"version", "code"})
+
+	kubernetesAPIRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "handler_kubernetes_api_retries_total",
+		Help: "Number of retried Kubernetes API requests.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(taskDuration, taskFailures, collectRequests, kubernetesAPIRetries)
+}
+
+// metricsListener records the duration and failures of tasks. It implements TaskListener.
+type metricsListener struct {
+	sync.Mutex
+	startTimes map[int]time.Time
+}
+
+// ContextWithMetrics returns a context in which Action.Run records the duration and failures of tasks.
+func ContextWithMetrics(ctx context.Context) context.Context {
+	return ContextWithListener(ctx, &metricsListener{
+		startTimes: make(map[int]time.Time),
+	})
+}
+
+// TaskSkipped is a no-op; skipped tasks are not recorded.
+func (ml *metricsListener) TaskSkipped(ctx context.Context, index int, t Task, reason string) {}
+
+// TaskStarted records the start time of the task.
+func (ml *metricsListener) TaskStarted(ctx context.Context, index int, t Task) {
+	ml.Lock()
+	defer ml.Unlock()
+	ml.startTimes[index] = time.Now()
+}
+
+// TaskRetried is a no-op; the duration of the task includes retries.
+func (ml *metricsListener) TaskRetried(ctx context.Context, index int, t Task, attempt int, err error) {
+}
+
+// TaskEnded records the duration of the task, and its failure, if any.
+func (ml *metricsListener) TaskEnded(ctx context.Context, index int, t Task, attempts int, err error) {
+	ml.Lock()
+	defer ml.Unlock()
+	name := GetTaskName(t)
+	if start, ok := ml.startTimes[index]; ok {
+		taskDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	}
+	if err != nil {
+		taskFailures.WithLabelValues(name).Inc()
+	}
+}
+
+// RecordCollectRequests records the number of requests sent to a version by the metrics/collect task, which received the given response code.
+func RecordCollectRequests(version string, code string, count int) {
+	collectRequests.WithLabelValues(version, code).Add(float64(count))
+}
+
+// PushMetrics pushes the handler metrics to a Pushgateway-compatible endpoint at url, under the MetricsJob job.
+// The grouping labels identify the handler run, for example, by experiment and action.
+func PushMetrics(url string, grouping map[string]string) error {
+	pusher := push.New(url, MetricsJob).Gatherer(metricsRegistry)
+	for name, value := range grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+	log.Trace("pushing metrics to: ", url)
+	return pusher.Push()
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushMetrics(t *testing.T) {
+	var path string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx := ContextWithMetrics(getRetryContext(t))
+	action := Action{
+		&testTask{TaskMeta: TaskMeta{Task: StringPointer("test/good")}},
+		&badTestTask{TaskMeta: TaskMeta{Task: StringPointer("test/bad")}},
+	}
+	assert.Error(t, action.Run(ctx))
+	RecordCollectRequests("default", "200", 10)
+
+	assert.NoError(t, PushMetrics(server.URL, map[string]string{"action": "start"}))
+	assert.Equal(t, "/metrics/job/"+MetricsJob+"/action/start", path)
+	for _, name := range []string{
+		"handler_task_duration_seconds",
+		"handler_task_failures_total",
+		"handler_collect_requests_total",
+		"handler_kubernetes_api_retries_total",
+		"test/good",
+		"test/bad",
+	} {
+		assert.Contains(t, string(body), name)
+	}
+
+	server.Close()
+	assert.Error(t, PushMetrics(server.URL, nil))
+}
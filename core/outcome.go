@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ActionOutcomeAnnotationPrefix is the prefix of the experiment annotations that hold the outcomes of actions.
+// The outcome of an action is stored as JSON under the annotation <prefix><action name>.
+// Annotations are used instead of status conditions, since condition types are restricted by the experiment CRD, and the status is owned by the controller.
+const ActionOutcomeAnnotationPrefix string = "handler.iter8.tools/"
+
+// ActionOutcome is the result of an action run.
+type ActionOutcome struct {
+	// Action is the name of the action
+	Action string `json:"action" yaml:"action"`
+	// Succeeded is true if the action succeeded
+	Succeeded bool `json:"succeeded" yaml:"succeeded"`
+	// FailedTaskIndex is the index of the first task that failed the action, if any
+	FailedTaskIndex *int `json:"failedTaskIndex,omitempty" yaml:"failedTaskIndex,omitempty"`
+	// FailedTask is the name of the first task that failed the action, if any
+	FailedTask *string `json:"failedTask,omitempty" yaml:"failedTask,omitempty"`
+	// Error is the error of the action, if any
+	Error *string `json:"error,omitempty" yaml:"error,omitempty"`
+	// Time is the time at which the action ended
+	Time time.Time `json:"time" yaml:"time"`
+}
+
+// Outcome returns the outcome of the action recorded in the report.
+// Tasks that continued on error do not fail the action.
+func (r *Report) Outcome() *ActionOutcome {
+	r.Lock()
+	defer r.Unlock()
+	o := &ActionOutcome{
+		Action:    r.Action,
+		Succeeded: r.Error == nil,
+		Error:     r.Error,
+		Time:      time.Now(),
+	}
+	if r.EndTime != nil {
+		o.Time = *r.EndTime
+	}
+	for _, tr := range r.Tasks {
+		if tr.Error != nil && !tr.ContinuedOnError {
+			o.FailedTaskIndex = &tr.Index
+			o.FailedTask = StringPointer(tr.Name)
+			break
+		}
+	}
+	return o
+}
+
+// PatchExperimentAnnotations merges the given annotations into those of the experiment.
+// By default, the experiment is patched within the cluster; see UseExperimentFile for running without a cluster.
+var PatchExperimentAnnotations = PatchInClusterExperimentAnnotations
+
+// PatchInClusterExperimentAnnotations merges the given annotations into those of the experiment within the cluster.
+// A merge patch is used, so that concurrent changes to the experiment do not conflict. Failed requests are retried until ctx is done.
+func PatchInClusterExperimentAnnotations(ctx context.Context, e *Experiment, annotations map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	c, err := GetClient()
+	if err != nil {
+		return err
+	}
+	return withKubernetesRetries(ctx, func(ctx context.Context) error {
+		return c.Patch(ctx, e, client.RawPatch(types.MergePatchType, patch))
+	})
+}
+
+// RecordActionOutcome stores the outcome of an action in an annotation of the experiment.
+func RecordActionOutcome(ctx context.Context, e *Experiment, o *ActionOutcome) error {
+	value, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	annotations := map[string]string{
+		ActionOutcomeAnnotationPrefix + o.Action: string(value),
+	}
+	log.Trace("recording action outcome: ", string(value))
+	if e.Annotations == nil {
+		e.Annotations = make(map[string]string)
+	}
+	for k, v := range annotations {
+		e.Annotations[k] = v
+	}
+	return PatchExperimentAnnotations(ctx, e, annotations)
+}
+
+// GetActionOutcome returns the outcome of the named action, as recorded in the annotations of the experiment.
+// Returns nil if the action has no recorded outcome.
+func (exp *Experiment) GetActionOutcome(action string) *ActionOutcome {
+	value, ok := exp.Annotations[ActionOutcomeAnnotationPrefix+action]
+	if !ok {
+		return nil
+	}
+	o := &ActionOutcome{}
+	if err := json.Unmarshal([]byte(value), o); err != nil {
+		log.Warn("invalid outcome of action ", action, ": ", err)
+		return nil
+	}
+	return o
+}
+
+// ActionSucceeded returns true if the named action has run and succeeded.
+// This can be used in conditions, for example, if: ActionSucceeded("start").
+func (exp *Experiment) ActionSucceeded(action string) bool {
+	o := exp.GetActionOutcome(action)
+	return o != nil && o.Succeeded
+}
+
+// ActionFailedTask returns the name of the task that failed the named action, or the empty string if there is none.
+// This can be used in conditions, for example, if: ActionFailedTask("start") != "common/readiness".
+func (exp *Experiment) ActionFailedTask(action string) string {
+	o := exp.GetActionOutcome(action)
+	if o == nil || o.FailedTask == nil {
+		return ""
+	}
+	return *o.FailedTask
+}
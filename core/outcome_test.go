@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	iter8 "github.com/iter8-tools/etc3/api/v2alpha2"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReportOutcome(t *testing.T) {
+	ctx := getRetryContext(t)
+	exp, _ := GetExperimentFromContext(ctx)
+
+	report := NewReport(exp, "start")
+	action := Action{
+		&badTestTask{TaskMeta: TaskMeta{Task: StringPointer("test/ignored"), ContinueOnError: BoolPointer(true)}},
+		&testTask{TaskMeta: TaskMeta{Task: StringPointer("test/good")}},
+	}
+	report.End(action.Run(ContextWithReport(ctx, report)))
+	o := report.Outcome()
+	assert.True(t, o.Succeeded)
+	assert.Nil(t, o.FailedTask)
+	assert.True(t, report.Tasks[0].ContinuedOnError)
+
+	report = NewReport(exp, "start")
+	action = append(action, &badTestTask{TaskMeta: TaskMeta{Task: StringPointer("test/bad")}})
+	report.End(action.Run(ContextWithReport(ctx, report)))
+	o = report.Outcome()
+	assert.False(t, o.Succeeded)
+	assert.Equal(t, 2, *o.FailedTaskIndex)
+	assert.Equal(t, "test/bad", *o.FailedTask)
+	assert.Equal(t, "shouldn't have run", *o.Error)
+}
+
+func TestRecordActionOutcome(t *testing.T) {
+	scheme := runtime.NewScheme()
+	metav1.AddToGroupVersion(scheme, iter8.GroupVersion)
+	scheme.AddKnownTypes(iter8.GroupVersion, &Experiment{})
+
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/experiment10.yaml")).Build()
+	assert.NoError(t, err)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(exp).Build()
+	defer func(f func() (client.Client, error)) { GetClient = f }(GetClient)
+	GetClient = func() (client.Client, error) {
+		return c, nil
+	}
+
+	assert.False(t, exp.ActionSucceeded("start"))
+	assert.Equal(t, "", exp.ActionFailedTask("start"))
+
+	assert.NoError(t, RecordActionOutcome(context.Background(), exp, &ActionOutcome{
+		Action:     "start",
+		FailedTask: StringPointer("common/readiness"),
+		Error:      StringPointer("not ready"),
+	}))
+	assert.False(t, exp.ActionSucceeded("start"))
+	assert.Equal(t, "common/readiness", exp.ActionFailedTask("start"))
+
+	inCluster := &Experiment{}
+	assert.NoError(t, c.Get(context.Background(), types.NamespacedName{Namespace: exp.Namespace, Name: exp.Name}, inCluster))
+	assert.Equal(t, "common/readiness", inCluster.ActionFailedTask("start"))
+
+	// outcomes can be used in conditions
+	ctx := context.WithValue(context.Background(), ContextKey("experiment"), inCluster)
+	assert.NoError(t, RunTask(ctx, &badTestTask{TaskMeta: TaskMeta{If: StringPointer("ActionFailedTask('start') != 'common/readiness'")}}))
+	assert.Error(t, RunTask(ctx, &badTestTask{TaskMeta: TaskMeta{If: StringPointer("not ActionSucceeded('start')")}}))
+
+	missing := &Experiment{Experiment: *iter8.NewExperiment("missing", "default").Build()}
+	assert.Error(t, RecordActionOutcome(context.Background(), missing, &ActionOutcome{Action: "start"}))
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"sync"
+)
+
+// taskIDRegexp matches valid task ids; ids need to be usable as identifiers in templates and conditions
+var taskIDRegexp = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+
+// taskOutputs holds the outputs published by tasks in an action, indexed by task id and output name.
+type taskOutputs struct {
+	sync.RWMutex
+	m map[string]map[string]interface{}
+}
+
+// validateTaskID validates the id of a task.
+func validateTaskID(id string) error {
+	if !taskIDRegexp.MatchString(id) {
+		return errors.New("task id needs to start with a letter or underscore and contain only letters, digits and underscores: " + id)
+	}
+	return nil
+}
+
+// ContextWithOutputs returns a context in which tasks can publish outputs.
+// If ctx already holds outputs, it is returned as is.
+func ContextWithOutputs(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(ContextKey("outputs")).(*taskOutputs); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, ContextKey("outputs"), &taskOutputs{
+		m: make(map[string]map[string]interface{}),
+	})
+}
+
+// contextWithTaskID returns a context that identifies the currently running task.
+func contextWithTaskID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ContextKey("task"), id)
+}
+
+// SetOutput publishes a named output of the currently running task.
+// Outputs of tasks without an id cannot be referenced by later tasks, and are not recorded.
+func SetOutput(ctx context.Context, name string, value interface{}) {
+	id, _ := ctx.Value(ContextKey("task")).(string)
+	outputs, ok := ctx.Value(ContextKey("outputs")).(*taskOutputs)
+	if len(id) == 0 || !ok {
+		return
+	}
+	log.WithField("task", id).Trace("setting output: ", name)
+	outputs.Lock()
+	defer outputs.Unlock()
+	if outputs.m[id] == nil {
+		outputs.m[id] = make(map[string]interface{})
+	}
+	outputs.m[id][name] = value
+}
+
+// GetOutputs returns the outputs published so far by tasks in the action.
+// Outputs are indexed as <id>.outputs.<name>, so that they can be referenced as tasks.<id>.outputs.<name> in templates and conditions.
+func GetOutputs(ctx context.Context) map[string]interface{} {
+	tasks := make(map[string]interface{})
+	outputs, ok := ctx.Value(ContextKey("outputs")).(*taskOutputs)
+	if !ok {
+		return tasks
+	}
+	outputs.RLock()
+	defer outputs.RUnlock()
+	for id, m := range outputs.m {
+		o := make(map[string]interface{}, len(m))
+		for name, value := range m {
+			o[name] = value
+		}
+		tasks[id] = map[string]interface{}{
+			"outputs": o,
+		}
+	}
+	return tasks
+}
+
+// WithOutputs adds the outputs published so far by tasks in the action to tags, under the label tasks.
+func (tags Tags) WithOutputs(ctx context.Context) Tags {
+	return tags.With("tasks", GetOutputs(ctx))
+}
+
+// getConditionEnv returns the environment in which 'if' conditions of tasks are evaluated.
+// The environment contains nil-safe helpers such as winnerFound() and metric(version, name), the exported fields and methods of the experiment,
+// and the outputs of tasks as tasks.
+func getConditionEnv(ctx context.Context, exp *Experiment) map[string]interface{} {
+	env := conditionHelpers(exp)
+	env["tasks"] = GetOutputs(ctx)
+	addToEnv(env, reflect.ValueOf(exp), true)
+	return env
+}
+
+// addToEnv adds the exported fields of v to env, and its exported methods if methods is true, including those promoted from embedded structs.
+// Names already in env take precedence.
+func addToEnv(env map[string]interface{}, v reflect.Value, methods bool) {
+	for i := 0; methods && i < v.NumMethod(); i++ {
+		if name := v.Type().Method(i).Name; !inEnv(env, name) {
+			env[name] = v.Method(i).Interface()
+		}
+	}
+	s := reflect.Indirect(v)
+	if s.Kind() != reflect.Struct {
+		return
+	}
+	// fields at this level take precedence over promoted fields
+	for i := 0; i < s.NumField(); i++ {
+		if f := s.Type().Field(i); f.PkgPath == "" && !inEnv(env, f.Name) {
+			env[f.Name] = s.Field(i).Interface()
+		}
+	}
+	for i := 0; i < s.NumField(); i++ {
+		if f := s.Type().Field(i); f.PkgPath == "" && f.Anonymous && s.Field(i).CanAddr() {
+			addToEnv(env, s.Field(i).Addr(), methods)
+		}
+	}
+}
+
+// inEnv determines if name is already in env.
+func inEnv(env map[string]interface{}, name string) bool {
+	_, ok := env[name]
+	return ok
+}
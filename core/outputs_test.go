@@ -0,0 +1,90 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type outputTestTask struct {
+	TaskMeta
+	name  string
+	value interface{}
+}
+
+func (t *outputTestTask) Run(ctx context.Context) error {
+	SetOutput(ctx, t.name, t.value)
+	return nil
+}
+
+func TestSetOutput(t *testing.T) {
+	// no outputs in context
+	ctx := contextWithTaskID(context.Background(), "build")
+	SetOutput(ctx, "digest", "sha256:abc")
+	assert.Empty(t, GetOutputs(ctx))
+
+	// no task id in context
+	ctx = ContextWithOutputs(context.Background())
+	SetOutput(ctx, "digest", "sha256:abc")
+	assert.Empty(t, GetOutputs(ctx))
+
+	// outputs are indexed by task id
+	SetOutput(contextWithTaskID(ctx, "build"), "digest", "sha256:abc")
+	assert.Equal(t, map[string]interface{}{
+		"build": map[string]interface{}{
+			"outputs": map[string]interface{}{
+				"digest": "sha256:abc",
+			},
+		},
+	}, GetOutputs(ctx))
+
+	// adding outputs again keeps existing ones
+	assert.Equal(t, ctx, ContextWithOutputs(ctx))
+}
+
+func TestValidateTaskID(t *testing.T) {
+	assert.NoError(t, validateTaskID("build_1"))
+	assert.Error(t, validateTaskID("build-1"))
+	assert.Error(t, validateTaskID("1build"))
+	assert.Error(t, validateTaskID(""))
+}
+
+func TestOutputsInAction(t *testing.T) {
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/experiment10.yaml")).Build()
+	assert.NoError(t, err)
+	ctx := context.WithValue(context.Background(), ContextKey("experiment"), exp)
+
+	t1 := &outputTestTask{
+		TaskMeta: TaskMeta{ID: StringPointer("build")},
+		name:     "digest",
+		value:    "sha256:abc",
+	}
+	// runs since the output is set
+	t2 := &flakyTestTask{
+		TaskMeta: TaskMeta{If: StringPointer("tasks.build.outputs.digest == 'sha256:abc'")},
+	}
+	// skipped since the output differs
+	t3 := &flakyTestTask{
+		TaskMeta: TaskMeta{If: StringPointer("tasks.build.outputs.digest == 'sha256:def'")},
+	}
+	// existing conditions over the experiment keep working
+	t4 := &flakyTestTask{
+		TaskMeta: TaskMeta{If: StringPointer("!WinnerFound() && Status.VersionRecommendedForPromotion != nil && Name != ''")},
+	}
+	action := Action{t1, t2, t3, t4}
+	assert.NoError(t, action.Run(ctx))
+	assert.Equal(t, 1, t2.attempts)
+	assert.Equal(t, 0, t3.attempts)
+	assert.Equal(t, 1, t4.attempts)
+}
+
+func TestOutputsInTags(t *testing.T) {
+	ctx := ContextWithOutputs(context.Background())
+	SetOutput(contextWithTaskID(ctx, "build"), "digest", "sha256:abc")
+	tags := NewTags().WithOutputs(ctx)
+	str := "image@{{ .tasks.build.outputs.digest }}"
+	out, err := tags.Interpolate(&str)
+	assert.NoError(t, err)
+	assert.Equal(t, "image@sha256:abc", out)
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Planner is implemented by tasks that support dry runs.
+// Plan writes what the task would do to w, with all interpolated values rendered.
+// It must not execute commands, send requests, or update the experiment status.
+type Planner interface {
+	Plan(ctx context.Context, w io.Writer) error
+}
+
+// Plan writes the plan of the given action to w, without running any of its tasks.
+// Sensitive values, such as the data of secrets, are masked in the plan.
+// The returned error aggregates the errors of all tasks that could not be planned.
+func (a *Action) Plan(ctx context.Context, w io.Writer) error {
+	rw := RedactingWriter(w)
+	defer rw.Close()
+	w = rw
+	ctx = ContextWithOutputs(ctx)
+	var errs []error
+	for i := 0; i < len(*a); i++ {
+		fmt.Fprintf(w, "------ task %d: %s\n", i, GetTaskName((*a)[i]))
+		if err := PlanTask(ctx, (*a)[i], w); err != nil {
+			fmt.Fprintln(w, "cannot plan task:", err)
+			errs = append(errs, fmt.Errorf("task %d: %w", i, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// PlanTask evaluates the condition of the given task, if any, and writes the plan of the task to w.
+// Tasks that do not implement Planner are reported as such, but are not run.
+func PlanTask(ctx context.Context, t Task, w io.Writer) error {
+	shouldRun, err := evaluateCondition(ctx, t)
+	if err != nil {
+		return err
+	}
+	if cond := t.GetIf(); cond != nil {
+		fmt.Fprintf(w, "if: %s => %t\n", *cond, shouldRun)
+	}
+	if !shouldRun {
+		fmt.Fprintln(w, "task would be skipped")
+		return nil
+	}
+	p, ok := t.(Planner)
+	if !ok {
+		fmt.Fprintln(w, "task would be run; it does not support dry runs, so no further details are available")
+		return nil
+	}
+	return p.Plan(contextWithTaskID(ctx, getTaskID(t)), w)
+}
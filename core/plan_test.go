@@ -0,0 +1,71 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type planTestTask struct {
+	TaskMeta
+	ran bool
+}
+
+func (t *planTestTask) Run(ctx context.Context) error {
+	t.ran = true
+	return nil
+}
+
+func (t *planTestTask) Plan(ctx context.Context, w io.Writer) error {
+	fmt.Fprintln(w, "would say hello")
+	return nil
+}
+
+func TestActionPlan(t *testing.T) {
+	t1 := planTestTask{TaskMeta: TaskMeta{Task: StringPointer("hello/world")}}
+	t2 := planTestTask{TaskMeta: TaskMeta{Task: StringPointer("hello/world"), If: StringPointer("WinnerFound()")}}
+	t3 := badTestTask{TaskMeta: TaskMeta{Task: StringPointer("bad/task")}}
+	action := Action{&t1, &t2, &t3}
+
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/experiment10.yaml")).Build()
+	assert.NoError(t, err)
+	ctx := context.WithValue(context.Background(), ContextKey("experiment"), exp)
+
+	var buf bytes.Buffer
+	assert.NoError(t, action.Plan(ctx, &buf))
+	assert.False(t, t1.ran)
+	assert.False(t, t2.ran)
+	assert.Contains(t, buf.String(), "------ task 0: hello/world\nwould say hello\n")
+	assert.Contains(t, buf.String(), "if: WinnerFound() => false\ntask would be skipped\n")
+	assert.Contains(t, buf.String(), "------ task 2: bad/task\ntask would be run; it does not support dry runs")
+
+	t3.If = StringPointer("AnotherOneBytesTheDust()")
+	assert.Error(t, action.Plan(ctx, &buf))
+}
+
+type leakyPlanTestTask struct {
+	planTestTask
+}
+
+func (t *leakyPlanTestTask) Plan(ctx context.Context, w io.Writer) error {
+	fmt.Fprintln(w, "would send plan-secret-value")
+	return nil
+}
+
+func TestActionPlanRedacted(t *testing.T) {
+	RegisterSensitive("plan-secret-value")
+	action := Action{&leakyPlanTestTask{}}
+
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/experiment10.yaml")).Build()
+	assert.NoError(t, err)
+	ctx := context.WithValue(context.Background(), ContextKey("experiment"), exp)
+
+	var buf bytes.Buffer
+	assert.NoError(t, action.Plan(ctx, &buf))
+	assert.NotContains(t, buf.String(), "plan-secret-value")
+	assert.Contains(t, buf.String(), "would send "+RedactedValue+"\n")
+}
@@ -0,0 +1,231 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// RedactedValue replaces sensitive values in log lines and other output of the handler.
+	RedactedValue string = "*****"
+	// minSensitiveLength is the length of the shortest value that is redacted wherever it appears; shorter values are redacted only as whole words, so as not to mask parts of unrelated text.
+	minSensitiveLength int = 6
+	// maxRedactedLine is the length beyond which a redacting writer writes a line that has not ended yet.
+	maxRedactedLine int = 64 * 1024
+)
+
+// sensitiveValues are the values that are masked in log lines and other output, such as the data of secrets read during a run.
+var sensitiveValues = &redactor{values: make(map[string]bool)}
+
+// commonValues are redacted only as whole words, like short values, since they are likely to be parts of unrelated text.
+var commonValues = map[string]bool{
+	"default":   true,
+	"disabled":  true,
+	"enabled":   true,
+	"localhost": true,
+	"secret":    true,
+}
+
+// redactor masks the values registered with it.
+type redactor struct {
+	sync.RWMutex
+	// values are the registered values; the short and common ones, which are masked as whole words only, are true
+	values   map[string]bool
+	replacer *strings.Replacer
+	words    []string
+}
+
+// RegisterSensitive registers values that are masked in every log line of the handler, whatever the level,
+// and in the other output that goes through Redact or RedactingWriter, such as the plans, reports and commands of tasks.
+// The data of every secret read with GetSecret is registered; tasks can register other values, such as tokens they derive.
+// Values shorter than 6 characters, and common values such as default, are masked only as whole words; for example, a secret value abc is masked in "key=abc", but not in "abcd".
+func RegisterSensitive(values ...string) {
+	sensitiveValues.register(values...)
+}
+
+// Redact masks the registered sensitive values in s.
+func Redact(s string) string {
+	return string(sensitiveValues.redact([]byte(s)))
+}
+
+// redactValue returns a copy of v, a value such as the outputs of a task, in which the registered sensitive values are masked in every string.
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case string:
+		return Redact(val)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, e := range val {
+			out[k] = redactValue(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, e := range val {
+			out[i] = redactValue(e)
+		}
+		return out
+	case []string:
+		out := make([]string, len(val))
+		for i, e := range val {
+			out[i] = Redact(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// RedactingWriter returns a writer that masks the registered sensitive values in what it writes to w.
+// Output is written line by line, so that values split across writes are masked; Close writes the last line if it has not ended.
+func RedactingWriter(w io.Writer) io.WriteCloser {
+	return &redactingWriter{w: w}
+}
+
+// redactingWriter buffers the line being written, and writes it to w with sensitive values masked once it ends.
+type redactingWriter struct {
+	sync.Mutex
+	w    io.Writer
+	line []byte
+}
+
+// Write writes the lines that end in p, with sensitive values masked, and buffers the rest.
+func (rw *redactingWriter) Write(p []byte) (int, error) {
+	rw.Lock()
+	defer rw.Unlock()
+	rw.line = append(rw.line, p...)
+	n := bytes.LastIndexByte(rw.line, '\n') + 1
+	if n == 0 && len(rw.line) < maxRedactedLine {
+		return len(p), nil
+	}
+	if n == 0 {
+		n = len(rw.line)
+	}
+	_, err := rw.w.Write(sensitiveValues.redact(rw.line[:n]))
+	rw.line = append(rw.line[:0], rw.line[n:]...)
+	return len(p), err
+}
+
+// Close writes the buffered line, if any, with sensitive values masked. It does not close w.
+func (rw *redactingWriter) Close() error {
+	rw.Lock()
+	defer rw.Unlock()
+	if len(rw.line) == 0 {
+		return nil
+	}
+	_, err := rw.w.Write(sensitiveValues.redact(rw.line))
+	rw.line = nil
+	return err
+}
+
+// register adds values to the redactor.
+func (r *redactor) register(values ...string) {
+	r.Lock()
+	defer r.Unlock()
+	changed := false
+	for _, v := range values {
+		if len(v) == 0 {
+			continue
+		}
+		word := len(v) < minSensitiveLength || commonValues[strings.ToLower(v)]
+		// values are also masked where they are escaped, as in quoted fields
+		for _, form := range []string{v, strings.Trim(strconv.Quote(v), `"`)} {
+			if w, ok := r.values[form]; !ok || (w && !word) {
+				r.values[form] = word
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return
+	}
+	// longer values are masked first, so that values containing others are masked whole
+	sorted := make([]string, 0, len(r.values))
+	for v := range r.values {
+		sorted = append(sorted, v)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) > len(sorted[j])
+		}
+		return sorted[i] < sorted[j]
+	})
+	oldnew := make([]string, 0, 2*len(sorted))
+	r.words = nil
+	for _, v := range sorted {
+		if r.values[v] {
+			r.words = append(r.words, v)
+		} else {
+			oldnew = append(oldnew, v, RedactedValue)
+		}
+	}
+	if len(oldnew) > 0 {
+		r.replacer = strings.NewReplacer(oldnew...)
+	}
+}
+
+// redact masks the registered values in b.
+func (r *redactor) redact(b []byte) []byte {
+	r.RLock()
+	replacer, words := r.replacer, r.words
+	r.RUnlock()
+	if replacer == nil && len(words) == 0 {
+		return b
+	}
+	s := string(b)
+	if replacer != nil {
+		s = replacer.Replace(s)
+	}
+	for _, w := range words {
+		s = maskWord(s, w)
+	}
+	return []byte(s)
+}
+
+// maskWord masks the occurrences of w in s that are not preceded or followed by a letter, digit or underscore.
+func maskWord(s string, w string) string {
+	if !strings.Contains(s, w) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; ; {
+		j := strings.Index(s[i:], w)
+		if j < 0 {
+			b.WriteString(s[i:])
+			return b.String()
+		}
+		start, end := i+j, i+j+len(w)
+		if (start > 0 && isWordByte(s[start-1])) || (end < len(s) && isWordByte(s[end])) {
+			b.WriteString(s[i:end])
+		} else {
+			b.WriteString(s[i:start])
+			b.WriteString(RedactedValue)
+		}
+		i = end
+	}
+}
+
+// isWordByte determines if c is part of a word: a letter, digit or underscore, or a byte of a multi-byte character.
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 0x80 || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// redactingFormatter masks sensitive values in the log lines formatted by the wrapped formatter.
+type redactingFormatter struct {
+	logrus.Formatter
+}
+
+// Format formats the entry, and masks sensitive values in it.
+func (f *redactingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	b, err := f.Formatter.Format(entry)
+	if err != nil {
+		return b, err
+	}
+	return sensitiveValues.redact(b), nil
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	defer SetLogLevel(logrus.InfoLevel)
+	SetLogLevel(logrus.TraceLevel)
+
+	defer SetClient(nil)
+	c := getFakeClient(t)
+	SetClient(c)
+	assert.NoError(t, c.Create(context.Background(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "github", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("ghp_redaction_test"), "user": []byte("bot")},
+	}))
+	_, err := GetSecret(context.Background(), "default/github")
+	assert.NoError(t, err)
+	RegisterSensitive("derived-credential", `multi "quoted"`+"\nline")
+
+	log.Trace("token: ghp_redaction_test")
+	log.WithField("auth", "Bearer ghp_redaction_test").Error("request failed")
+	log.Info("derived-credential", "; ", map[string]string{"user": "bot", "robot": "robots"})
+	log.WithField("value", `multi "quoted"`+"\nline").Warn("quoted")
+
+	out := buf.String()
+	assert.NotContains(t, out, "ghp_redaction_test")
+	assert.NotContains(t, out, "derived-credential")
+	assert.NotContains(t, out, `quoted\"\nline`)
+	assert.Contains(t, out, "token: "+RedactedValue)
+	assert.Contains(t, out, "auth=Bearer "+RedactedValue)
+	// short values are masked as whole words only
+	assert.Contains(t, out, "user:"+RedactedValue)
+	assert.Contains(t, out, "robot:robots")
+}
+
+func TestRedact(t *testing.T) {
+	RegisterSensitive("redact-me-please", "xq7", "Disabled", "")
+	assert.Equal(t, "token "+RedactedValue, Redact("token redact-me-please"))
+	// short and common values are masked as whole words only
+	assert.Equal(t, "key="+RedactedValue+" "+RedactedValue+".", Redact("key=xq7 Disabled."))
+	assert.Equal(t, "xq78 _xq7 Disabledness", Redact("xq78 _xq7 Disabledness"))
+
+	// values split across writes are masked
+	var buf bytes.Buffer
+	w := RedactingWriter(&buf)
+	for _, p := range []string{"first redact-", "me-please\nsecond ", "redact-me", "-please"} {
+		n, err := w.Write([]byte(p))
+		assert.NoError(t, err)
+		assert.Equal(t, len(p), n)
+	}
+	assert.Equal(t, "first "+RedactedValue+"\n", buf.String())
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "first "+RedactedValue+"\nsecond "+RedactedValue, buf.String())
+}
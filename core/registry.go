@@ -0,0 +1,159 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/iter8-tools/etc3/api/v2alpha2"
+)
+
+// TaskFactory constructs a Task from a TaskSpec.
+type TaskFactory func(*v2alpha2.TaskSpec) (Task, error)
+
+// registry holds the task factories indexed by task name, the names of task groups, and the factory of run specs.
+var registry = struct {
+	sync.RWMutex
+	factories map[string]TaskFactory
+	groups    map[string]bool
+	run       TaskFactory
+}{
+	factories: make(map[string]TaskFactory),
+	groups:    make(map[string]bool),
+}
+
+// RegisterTask makes a task factory available under the given task name.
+// Task packages are expected to call this (or MustRegisterTask) from their init function.
+// A handler binary with additional tasks imports their packages alongside cmd in its main package, and calls cmd.Execute.
+// Returns error if the name is empty, the factory is nil, or the name is already registered.
+func RegisterTask(name string, factory TaskFactory) error {
+	if len(name) == 0 {
+		return errors.New("cannot register task with empty name")
+	}
+	if factory == nil {
+		return errors.New("cannot register task " + name + " with nil factory")
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.factories[name]; ok {
+		return errors.New("task already registered: " + name)
+	}
+	registry.factories[name] = factory
+	return nil
+}
+
+// MustRegisterTask is like RegisterTask but panics if the task cannot be registered.
+func MustRegisterTask(name string, factory TaskFactory) {
+	if err := RegisterTask(name, factory); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterTaskGroup is like RegisterTask, for task groups: tasks whose 'tasks' input holds task and run specs, such as common/finally.
+// Include specs among the tasks of a group are expanded by ExpandIncludes.
+func RegisterTaskGroup(name string, factory TaskFactory) error {
+	if err := RegisterTask(name, factory); err != nil {
+		return err
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	registry.groups[name] = true
+	return nil
+}
+
+// MustRegisterTaskGroup is like RegisterTaskGroup but panics if the task group cannot be registered.
+func MustRegisterTaskGroup(name string, factory TaskFactory) {
+	if err := RegisterTaskGroup(name, factory); err != nil {
+		panic(err)
+	}
+}
+
+// isTaskGroup determines if the given task spec is the spec of a registered task group.
+func isTaskGroup(t *v2alpha2.TaskSpec) bool {
+	if t.Task == nil {
+		return false
+	}
+	registry.RLock()
+	defer registry.RUnlock()
+	return registry.groups[*t.Task]
+}
+
+// RegisterRun makes the factory of run specs available to MakeTaskOrRun.
+// The runscript package is expected to call this (or MustRegisterRun) from its init function.
+// Returns error if the factory is nil, or a factory of run specs is already registered.
+func RegisterRun(factory TaskFactory) error {
+	if factory == nil {
+		return errors.New("cannot register nil factory of run specs")
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.run != nil {
+		return errors.New("factory of run specs already registered")
+	}
+	registry.run = factory
+	return nil
+}
+
+// MustRegisterRun is like RegisterRun but panics if the factory cannot be registered.
+func MustRegisterRun(factory TaskFactory) {
+	if err := RegisterRun(factory); err != nil {
+		panic(err)
+	}
+}
+
+// LookupTask returns the task factory registered under the given name, if any.
+func LookupTask(name string) (TaskFactory, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	factory, ok := registry.factories[name]
+	return factory, ok
+}
+
+// RegisteredTasks returns the sorted names of all registered tasks.
+func RegisteredTasks() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.factories))
+	for name := range registry.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MakeTask constructs a Task from a TaskSpec using the registered task factories.
+// Returns error if the spec is nil or empty, or if no task is registered under its name.
+func MakeTask(t *v2alpha2.TaskSpec) (Task, error) {
+	if t == nil || t.Task == nil || len(*t.Task) == 0 {
+		return nil, errors.New("nil or empty task found")
+	}
+	factory, ok := LookupTask(*t.Task)
+	if !ok {
+		return nil, errors.New("unknown task: " + *t.Task)
+	}
+	task, err := factory(t)
+	if err != nil {
+		return nil, err
+	}
+	if err = LoadTaskMeta(task, t); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// MakeTaskOrRun constructs a Task from an item of an action or task group, which is either a run spec or a task spec.
+// Returns error if the item is neither, or if it is a run spec and no factory of run specs is registered.
+func MakeTaskOrRun(t *v2alpha2.TaskSpec) (Task, error) {
+	if IsARun(t) {
+		registry.RLock()
+		factory := registry.run
+		registry.RUnlock()
+		if factory == nil {
+			return nil, errors.New("no factory of run specs registered")
+		}
+		return factory(t)
+	} else if IsATask(t) {
+		return MakeTask(t)
+	}
+	return nil, errors.New("spec is neither run spec nor task spec")
+}
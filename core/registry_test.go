@@ -0,0 +1,84 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iter8-tools/etc3/api/v2alpha2"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeTestTask(t *v2alpha2.TaskSpec) (Task, error) {
+	return &testTask{
+		TaskMeta: TaskMeta{
+			Task: t.Task,
+		},
+	}, nil
+}
+
+func TestRegisterTask(t *testing.T) {
+	assert.NoError(t, RegisterTask("test/registry", makeTestTask))
+	assert.Contains(t, RegisteredTasks(), "test/registry")
+
+	// duplicate names are rejected
+	assert.Error(t, RegisterTask("test/registry", makeTestTask))
+	assert.Panics(t, func() { MustRegisterTask("test/registry", makeTestTask) })
+
+	// empty names and nil factories are rejected
+	assert.Error(t, RegisterTask("", makeTestTask))
+	assert.Error(t, RegisterTask("test/nil", nil))
+
+	factory, ok := LookupTask("test/registry")
+	assert.True(t, ok)
+	assert.NotNil(t, factory)
+	_, ok = LookupTask("test/absent")
+	assert.False(t, ok)
+}
+
+func TestMakeTask(t *testing.T) {
+	MustRegisterTask("test/make", makeTestTask)
+
+	task, err := MakeTask(&v2alpha2.TaskSpec{Task: StringPointer("test/make")})
+	assert.NoError(t, err)
+	assert.NoError(t, task.Run(context.Background()))
+
+	_, err = MakeTask(&v2alpha2.TaskSpec{Task: StringPointer("test/unknown")})
+	assert.Error(t, err)
+
+	_, err = MakeTask(&v2alpha2.TaskSpec{})
+	assert.Error(t, err)
+
+	_, err = MakeTask(nil)
+	assert.Error(t, err)
+}
+
+func TestMakeTaskOrRun(t *testing.T) {
+	MustRegisterTask("test/makeorrun", makeTestTask)
+
+	task, err := MakeTaskOrRun(&v2alpha2.TaskSpec{Task: StringPointer("test/makeorrun")})
+	assert.NoError(t, err)
+	assert.NotNil(t, task)
+
+	// run specs need a factory of run specs
+	registry.Lock()
+	run := registry.run
+	registry.run = nil
+	registry.Unlock()
+	defer func() {
+		registry.Lock()
+		registry.run = run
+		registry.Unlock()
+	}()
+	_, err = MakeTaskOrRun(&v2alpha2.TaskSpec{Run: StringPointer("echo hello")})
+	assert.Error(t, err)
+
+	assert.Error(t, RegisterRun(nil))
+	assert.NoError(t, RegisterRun(makeTestTask))
+	assert.Panics(t, func() { MustRegisterRun(makeTestTask) })
+	task, err = MakeTaskOrRun(&v2alpha2.TaskSpec{Run: StringPointer("echo hello")})
+	assert.NoError(t, err)
+	assert.NotNil(t, task)
+
+	_, err = MakeTaskOrRun(&v2alpha2.TaskSpec{})
+	assert.Error(t, err)
+}
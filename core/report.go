@@ -0,0 +1,234 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ReportKey is the key under which the report is stored in a ConfigMap
+	ReportKey string = "report.json"
+
+	// SkippedByCondition indicates that a task was skipped because its condition evaluated to false
+	SkippedByCondition string = "condition"
+	// SkippedByEarlierFailure indicates that a task was skipped because an earlier task in the action failed
+	SkippedByEarlierFailure string = "earlierFailure"
+)
+
+// Report is a machine-readable record of an action run.
+// It implements TaskListener, and records the run of each task in the action.
+type Report struct {
+	sync.Mutex `json:"-" yaml:"-"`
+	// Experiment is the namespace/name of the experiment
+	Experiment string `json:"experiment" yaml:"experiment"`
+	// Action is the name of the action
+	Action string `json:"action" yaml:"action"`
+	// StartTime is the time at which the action started
+	StartTime time.Time `json:"startTime" yaml:"startTime"`
+	// EndTime is the time at which the action ended
+	EndTime *time.Time `json:"endTime,omitempty" yaml:"endTime,omitempty"`
+	// Error is the error of the action, if any
+	Error *string `json:"error,omitempty" yaml:"error,omitempty"`
+	// Interrupted is true if the action was interrupted by a termination signal
+	Interrupted bool `json:"interrupted,omitempty" yaml:"interrupted,omitempty"`
+	// Tasks are the reports of the tasks in the action, in order
+	Tasks []*TaskReport `json:"tasks" yaml:"tasks"`
+}
+
+// TaskReport is a machine-readable record of a task run.
+type TaskReport struct {
+	// Index is the index of the task in the spec of the action; for an included task, it is the index of the include spec
+	Index int `json:"index" yaml:"index"`
+	// Include is the chain of includes through which the task was included, if any, for example, include file foo.yaml, task 1
+	Include *string `json:"include,omitempty" yaml:"include,omitempty"`
+	// Name is the name of the task, or run for run specs
+	Name string `json:"name" yaml:"name"`
+	// ID is the id of the task, if any
+	ID *string `json:"id,omitempty" yaml:"id,omitempty"`
+	// Skipped is true if the task was not run
+	Skipped bool `json:"skipped" yaml:"skipped"`
+	// SkipReason is either condition or earlierFailure, if the task was skipped
+	SkipReason *string `json:"skipReason,omitempty" yaml:"skipReason,omitempty"`
+	// StartTime is the time at which the task started, if it was run
+	StartTime *time.Time `json:"startTime,omitempty" yaml:"startTime,omitempty"`
+	// EndTime is the time at which the task ended, if it was run
+	EndTime *time.Time `json:"endTime,omitempty" yaml:"endTime,omitempty"`
+	// Attempts is the number of attempts of the task
+	Attempts int `json:"attempts" yaml:"attempts"`
+	// Error is the error of the task, if any
+	Error *string `json:"error,omitempty" yaml:"error,omitempty"`
+	// ContinuedOnError is true if the task failed, but the action continued since the task continues on error
+	ContinuedOnError bool `json:"continuedOnError,omitempty" yaml:"continuedOnError,omitempty"`
+	// Interrupted is true if the task was running when the action was interrupted by a termination signal
+	Interrupted bool `json:"interrupted,omitempty" yaml:"interrupted,omitempty"`
+	// Outputs are the outputs published by the task; only tasks with an id publish outputs
+	Outputs map[string]interface{} `json:"outputs,omitempty" yaml:"outputs,omitempty"`
+	// position is the index of the task in the action, after its includes are expanded
+	position int
+}
+
+// NewReport creates a report for a run of the named action of the experiment.
+func NewReport(exp *Experiment, action string) *Report {
+	return &Report{
+		Experiment: exp.Namespace + "/" + exp.Name,
+		Action:     action,
+		StartTime:  time.Now(),
+		Tasks:      []*TaskReport{},
+	}
+}
+
+// ContextWithReport returns a context in which Action.Run records the run of each task in r.
+func ContextWithReport(ctx context.Context, r *Report) context.Context {
+	return ContextWithListener(ctx, r)
+}
+
+// taskReport returns the report of the task at the given index, and creates it if needed.
+// Must be called with r locked.
+func (r *Report) taskReport(index int, t Task) *TaskReport {
+	for _, tr := range r.Tasks {
+		if tr.position == index {
+			return tr
+		}
+	}
+	l := taskLocation(index, t)
+	tr := &TaskReport{
+		Index:    l.Index,
+		Name:     GetTaskName(t),
+		position: index,
+	}
+	if len(l.Include) > 0 {
+		tr.Include = StringPointer(l.Include)
+	}
+	if id := getTaskID(t); len(id) > 0 {
+		tr.ID = StringPointer(id)
+	}
+	r.Tasks = append(r.Tasks, tr)
+	return tr
+}
+
+// TaskSkipped records that the task was skipped for the given reason.
+func (r *Report) TaskSkipped(ctx context.Context, index int, t Task, reason string) {
+	r.Lock()
+	defer r.Unlock()
+	tr := r.taskReport(index, t)
+	tr.Skipped = true
+	tr.SkipReason = StringPointer(reason)
+}
+
+// TaskStarted records the start of the task.
+func (r *Report) TaskStarted(ctx context.Context, index int, t Task) {
+	r.Lock()
+	defer r.Unlock()
+	now := time.Now()
+	r.taskReport(index, t).StartTime = &now
+}
+
+// TaskRetried is a no-op; the number of attempts is recorded when the task ends.
+func (r *Report) TaskRetried(ctx context.Context, index int, t Task, attempt int, err error) {}
+
+// TaskEnded records the end of the task, along with its attempts, error and outputs.
+// Sensitive values, such as the data of secrets, are masked in the recorded error and outputs.
+func (r *Report) TaskEnded(ctx context.Context, index int, t Task, attempts int, err error) {
+	r.Lock()
+	defer r.Unlock()
+	tr := r.taskReport(index, t)
+	now := time.Now()
+	tr.EndTime = &now
+	tr.Attempts = attempts
+	if err != nil {
+		tr.Error = StringPointer(Redact(err.Error()))
+		tr.ContinuedOnError = continuesOnError(t)
+	}
+	if tr.ID != nil {
+		if o, ok := GetOutputs(ctx)[*tr.ID].(map[string]interface{}); ok {
+			// the outputs of later tasks are unaffected; only the persisted copy is redacted
+			if outputs, ok := o["outputs"].(map[string]interface{}); ok {
+				tr.Outputs = redactValue(outputs).(map[string]interface{})
+			}
+		}
+	}
+}
+
+// End records the end of the action, along with its error, in which sensitive values are masked.
+func (r *Report) End(err error) {
+	r.Lock()
+	defer r.Unlock()
+	now := time.Now()
+	r.EndTime = &now
+	if err != nil {
+		r.Error = StringPointer(Redact(err.Error()))
+	}
+}
+
+// Interrupt records that the action was interrupted by a termination signal.
+// Returns the reports of the tasks that were running at the time, which are marked as interrupted.
+func (r *Report) Interrupt() []*TaskReport {
+	r.Lock()
+	defer r.Unlock()
+	r.Interrupted = true
+	running := []*TaskReport{}
+	for _, tr := range r.Tasks {
+		if tr.StartTime != nil && tr.EndTime == nil {
+			tr.Interrupted = true
+			running = append(running, tr)
+		}
+	}
+	return running
+}
+
+// ToJSON returns the report as indented JSON.
+func (r *Report) ToJSON() ([]byte, error) {
+	r.Lock()
+	defer r.Unlock()
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// ToFile writes the report as JSON to a file.
+// The file is created if it does not exist, and overwritten otherwise.
+func (r *Report) ToFile(filePath string) error {
+	data, err := r.ToJSON()
+	if err != nil {
+		return err
+	}
+	log.Trace("writing report to file: ", filePath)
+	return ioutil.WriteFile(filePath, data, 0644)
+}
+
+// ToConfigMap writes the report as JSON to a ConfigMap in the cluster, under the key report.json.
+// The ConfigMap is created if it does not exist, and updated otherwise. Failed requests are retried according to KubernetesRetry, until ctx is done.
+func (r *Report) ToConfigMap(ctx context.Context, nn *client.ObjectKey) error {
+	data, err := r.ToJSON()
+	if err != nil {
+		return err
+	}
+	c, err := GetClient()
+	if err != nil {
+		return err
+	}
+	log.Trace("writing report to configmap: ", nn)
+	return withKubernetesRetries(ctx, func(ctx context.Context) error {
+		cm := &corev1.ConfigMap{}
+		err := c.Get(ctx, *nn, cm)
+		if apierrors.IsNotFound(err) {
+			cm.Name = nn.Name
+			cm.Namespace = nn.Namespace
+			cm.Data = map[string]string{ReportKey: string(data)}
+			return c.Create(ctx, cm)
+		}
+		if err != nil {
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = make(map[string]string)
+		}
+		cm.Data[ReportKey] = string(data)
+		return c.Update(ctx, cm)
+	})
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestActionRunReport(t *testing.T) {
+	ctx := getRetryContext(t)
+	exp, _ := GetExperimentFromContext(ctx)
+	report := NewReport(exp, "start")
+
+	action := Action{
+		&outputTestTask{TaskMeta: TaskMeta{Task: StringPointer("test/output"), ID: StringPointer("build")}, name: "digest", value: "sha256:abc"},
+		&testTask{TaskMeta: TaskMeta{Task: StringPointer("test/conditional"), If: StringPointer("WinnerFound()")}},
+		&flakyTestTask{TaskMeta: TaskMeta{Task: StringPointer("test/flaky"), Retries: Int32Pointer(1), Backoff: &Backoff{Interval: StringPointer("1ms")}}, failures: 1},
+		&badTestTask{TaskMeta: TaskMeta{Task: StringPointer("test/bad")}},
+		&testTask{TaskMeta: TaskMeta{Task: StringPointer("test/skipped")}},
+		&finallyTestTask{TaskMeta: TaskMeta{Task: StringPointer("test/finally")}},
+	}
+	err := action.Run(ContextWithReport(ctx, report))
+	report.End(err)
+	assert.Error(t, err)
+
+	assert.Equal(t, exp.Namespace+"/"+exp.Name, report.Experiment)
+	assert.Equal(t, "start", report.Action)
+	assert.NotNil(t, report.EndTime)
+	assert.Equal(t, "[shouldn't have run, finally failed]", *report.Error)
+	assert.Len(t, report.Tasks, 6)
+
+	assert.Equal(t, "build", *report.Tasks[0].ID)
+	assert.Equal(t, 1, report.Tasks[0].Attempts)
+	assert.Equal(t, "sha256:abc", report.Tasks[0].Outputs["digest"])
+	assert.NotNil(t, report.Tasks[0].StartTime)
+	assert.NotNil(t, report.Tasks[0].EndTime)
+
+	assert.True(t, report.Tasks[1].Skipped)
+	assert.Equal(t, SkippedByCondition, *report.Tasks[1].SkipReason)
+	assert.Nil(t, report.Tasks[1].StartTime)
+
+	assert.Equal(t, 2, report.Tasks[2].Attempts)
+	assert.Nil(t, report.Tasks[2].Error)
+
+	assert.Equal(t, "test/bad", report.Tasks[3].Name)
+	assert.Equal(t, "shouldn't have run", *report.Tasks[3].Error)
+
+	assert.True(t, report.Tasks[4].Skipped)
+	assert.Equal(t, SkippedByEarlierFailure, *report.Tasks[4].SkipReason)
+
+	assert.Equal(t, 5, report.Tasks[5].Index)
+	assert.False(t, report.Tasks[5].Skipped)
+	assert.Equal(t, 1, report.Tasks[5].Attempts)
+	assert.Equal(t, "finally failed", *report.Tasks[5].Error)
+}
+
+func TestReportRedacted(t *testing.T) {
+	ctx := ContextWithOutputs(getRetryContext(t))
+	exp, _ := GetExperimentFromContext(ctx)
+	report := NewReport(exp, "start")
+	RegisterSensitive("report-secret-value")
+
+	action := Action{
+		&outputTestTask{TaskMeta: TaskMeta{Task: StringPointer("test/output"), ID: StringPointer("login")}, name: "token", value: "Bearer report-secret-value"},
+	}
+	assert.NoError(t, action.Run(ContextWithReport(ctx, report)))
+	assert.Equal(t, "Bearer "+RedactedValue, report.Tasks[0].Outputs["token"])
+	// later tasks still get the value as is
+	assert.Equal(t, "Bearer report-secret-value", GetOutputs(ctx)["login"].(map[string]interface{})["outputs"].(map[string]interface{})["token"])
+
+	report.End(errors.New("login with report-secret-value failed"))
+	assert.Equal(t, "login with "+RedactedValue+" failed", *report.Error)
+}
+
+func TestReportToFile(t *testing.T) {
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/experiment10.yaml")).Build()
+	assert.NoError(t, err)
+	report := NewReport(exp, "start")
+	report.End(errors.New("failed"))
+
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	assert.NoError(t, report.ToFile(reportFile))
+	data, err := ioutil.ReadFile(reportFile)
+	assert.NoError(t, err)
+	r := Report{}
+	assert.NoError(t, json.Unmarshal(data, &r))
+	assert.Equal(t, "start", r.Action)
+	assert.Equal(t, "failed", *r.Error)
+}
+
+func TestReportToConfigMap(t *testing.T) {
+	c := getFakeClient(t)
+	defer func(f func() (client.Client, error)) { GetClient = f }(GetClient)
+	GetClient = func() (client.Client, error) {
+		return c, nil
+	}
+
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/experiment10.yaml")).Build()
+	assert.NoError(t, err)
+	report := NewReport(exp, "start")
+	nn := &types.NamespacedName{Namespace: "default", Name: "report"}
+
+	// create
+	assert.NoError(t, report.ToConfigMap(context.Background(), nn))
+	cm := &corev1.ConfigMap{}
+	assert.NoError(t, c.Get(context.Background(), *nn, cm))
+	assert.Contains(t, cm.Data[ReportKey], `"action": "start"`)
+
+	// update
+	report.End(nil)
+	assert.NoError(t, report.ToConfigMap(context.Background(), nn))
+	assert.NoError(t, c.Get(context.Background(), *nn, cm))
+	assert.Contains(t, cm.Data[ReportKey], `"endTime"`)
+}
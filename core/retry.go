@@ -0,0 +1,161 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/antonmedv/expr"
+)
+
+const (
+	// ConstantBackoff waits for the same interval between all attempts
+	ConstantBackoff string = "constant"
+	// ExponentialBackoff doubles the interval after every attempt
+	ExponentialBackoff string = "exponential"
+
+	// DefaultBackoffInterval is the default interval between attempts
+	DefaultBackoffInterval string = "1s"
+)
+
+// Backoff determines the delay between attempts of a task.
+type Backoff struct {
+	// Type is optional and is either constant or exponential; defaulted to constant
+	Type *string `json:"type,omitempty" yaml:"type,omitempty"`
+	// Interval is optional and defaulted to 1s. This is the delay before the first retry.
+	Interval *string `json:"interval,omitempty" yaml:"interval,omitempty"`
+	// MaxInterval is optional and caps the delay between attempts when backoff is exponential
+	MaxInterval *string `json:"maxInterval,omitempty" yaml:"maxInterval,omitempty"`
+	// Jitter is optional and defaulted to 0. It is a fraction between 0 and 1; each delay is randomly varied by up to this fraction.
+	Jitter *float64 `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+}
+
+// retryEnv is the environment in which retryOn conditions are evaluated.
+type retryEnv struct {
+	// Error is the error message of the failed attempt
+	Error string
+	// Attempt is the number of the failed attempt, starting from 1
+	Attempt int
+}
+
+// validateRetryPolicy validates the retries, backoff, and retryOn fields of TaskMeta.
+func (tm *TaskMeta) validateRetryPolicy() error {
+	if tm.Retries != nil && *tm.Retries < 0 {
+		return errors.New("retries cannot be negative")
+	}
+	if err := tm.Backoff.validate(); err != nil {
+		return err
+	}
+	if tm.RetryOn != nil {
+		if _, err := expr.Compile(*tm.RetryOn, expr.Env(retryEnv{}), expr.AsBool()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate validates the type, intervals and jitter of the backoff, if any.
+func (b *Backoff) validate() error {
+	if b == nil {
+		return nil
+	}
+	if b.Type != nil && *b.Type != ConstantBackoff && *b.Type != ExponentialBackoff {
+		return errors.New("backoff type needs to be '" + ConstantBackoff + "' or '" + ExponentialBackoff + "'")
+	}
+	for _, d := range []*string{b.Interval, b.MaxInterval} {
+		if d != nil {
+			if _, err := time.ParseDuration(*d); err != nil {
+				return err
+			}
+		}
+	}
+	if b.Jitter != nil && (*b.Jitter < 0 || *b.Jitter > 1) {
+		return errors.New("backoff jitter needs to be between 0 and 1")
+	}
+	return nil
+}
+
+// delay returns the delay after the given (failed) attempt.
+func (b *Backoff) delay(attempt int) time.Duration {
+	interval, _ := time.ParseDuration(DefaultBackoffInterval)
+	if b == nil {
+		return interval
+	}
+	if b.Interval != nil {
+		interval, _ = time.ParseDuration(*b.Interval)
+	}
+	d := float64(interval)
+	if b.Type != nil && *b.Type == ExponentialBackoff {
+		d = d * math.Pow(2, float64(attempt-1))
+		if b.MaxInterval != nil {
+			maxInterval, _ := time.ParseDuration(*b.MaxInterval)
+			d = math.Min(d, float64(maxInterval))
+		}
+	}
+	if b.Jitter != nil {
+		d = d * (1 + *b.Jitter*(2*rand.Float64()-1))
+	}
+	return time.Duration(d)
+}
+
+// shouldRetry evaluates the retryOn condition, if any, for the error of a failed attempt.
+func (tm *TaskMeta) shouldRetry(err error, attempt int) (bool, error) {
+	if tm.RetryOn == nil {
+		return true, nil
+	}
+	env := retryEnv{
+		Error:   err.Error(),
+		Attempt: attempt,
+	}
+	program, err := expr.Compile(*tm.RetryOn, expr.Env(env), expr.AsBool())
+	if err != nil {
+		return false, err
+	}
+	output, err := expr.Run(program, env)
+	if err != nil {
+		return false, err
+	}
+	return output.(bool), nil
+}
+
+// runWithRetries runs the task, and retries it upon failure according to its retry policy.
+// Listeners of the run, if any, are notified of retries. Returns the number of attempts along with the error of the last attempt.
+func runWithRetries(ctx context.Context, t Task, run *taskRun) (int, error) {
+	tm := getTaskMeta(t)
+	retries := 0
+	if tm != nil && tm.Retries != nil {
+		retries = int(*tm.Retries)
+	}
+	name := GetTaskName(t)
+	entry := log.WithField("task", name)
+
+	for attempt := 1; ; attempt++ {
+		err := runWithTimeout(ctx, t)
+		if err == nil {
+			entry.Info("task succeeded; attempts: ", attempt)
+			return attempt, nil
+		}
+		entry.Warn("task failed; attempt: ", attempt, " of ", retries+1, "; error: ", err)
+		if attempt > retries {
+			return attempt, err
+		}
+		retry, rerr := tm.shouldRetry(err, attempt)
+		if rerr != nil {
+			entry.Error("cannot evaluate retryOn condition: ", rerr)
+			return attempt, err
+		}
+		if !retry {
+			entry.Info("retryOn condition is false; not retrying")
+			return attempt, err
+		}
+		delay := tm.Backoff.delay(attempt)
+		entry.Info("retrying task after ", delay)
+		run.retried(ctx, t, attempt, err)
+		if Sleep(ctx, delay) != nil {
+			entry.Warn("context done; not retrying")
+			return attempt, err
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iter8-tools/etc3/api/v2alpha2"
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+type flakyTestTask struct {
+	TaskMeta
+	failures int
+	attempts int
+}
+
+func (t *flakyTestTask) Run(ctx context.Context) error {
+	t.attempts++
+	if t.attempts <= t.failures {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func getRetryContext(t *testing.T) context.Context {
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/experiment10.yaml")).Build()
+	assert.NoError(t, err)
+	return context.WithValue(context.Background(), ContextKey("experiment"), exp)
+}
+
+func TestLoadTaskMeta(t *testing.T) {
+	task := &flakyTestTask{
+		TaskMeta: TaskMeta{
+			Task: StringPointer("test/flaky"),
+		},
+	}
+	with := map[string]apiextensionsv1.JSON{
+		"retries": {Raw: []byte(`3`)},
+		"backoff": {Raw: []byte(`{"type": "exponential", "interval": "10ms", "maxInterval": "1s", "jitter": 0.5}`)},
+		"retryOn": {Raw: []byte(`"Error contains 'refused'"`)},
+		"task":    {Raw: []byte(`"ignored"`)},
+	}
+	assert.NoError(t, LoadTaskMeta(task, &v2alpha2.TaskSpec{With: with}))
+	assert.Equal(t, "test/flaky", *task.Task)
+	assert.Equal(t, int32(3), *task.Retries)
+	assert.Equal(t, ExponentialBackoff, *task.Backoff.Type)
+	assert.Equal(t, "Error contains 'refused'", *task.RetryOn)
+
+	for _, bad := range []map[string]apiextensionsv1.JSON{
+		{"retries": {Raw: []byte(`-1`)}},
+		{"retries": {Raw: []byte(`"three"`)}},
+		{"backoff": {Raw: []byte(`{"type": "linear"}`)}},
+		{"backoff": {Raw: []byte(`{"interval": "soon"}`)}},
+		{"backoff": {Raw: []byte(`{"jitter": 2}`)}},
+		{"retryOn": {Raw: []byte(`"Error +"`)}},
+	} {
+		assert.Error(t, LoadTaskMeta(&flakyTestTask{}, &v2alpha2.TaskSpec{With: bad}))
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	var b *Backoff
+	assert.Equal(t, time.Second, b.delay(3))
+
+	b = &Backoff{Interval: StringPointer("10ms")}
+	assert.Equal(t, 10*time.Millisecond, b.delay(3))
+
+	b.Type = StringPointer(ExponentialBackoff)
+	assert.Equal(t, 40*time.Millisecond, b.delay(3))
+
+	b.MaxInterval = StringPointer("20ms")
+	assert.Equal(t, 20*time.Millisecond, b.delay(3))
+
+	b.Jitter = Float64Pointer(0.5)
+	d := b.delay(3)
+	assert.GreaterOrEqual(t, int64(d), int64(10*time.Millisecond))
+	assert.LessOrEqual(t, int64(d), int64(30*time.Millisecond))
+}
+
+func TestRunWithRetries(t *testing.T) {
+	ctx := getRetryContext(t)
+
+	// succeeds on the last attempt
+	task := &flakyTestTask{
+		TaskMeta: TaskMeta{
+			Retries: Int32Pointer(2),
+			Backoff: &Backoff{Interval: StringPointer("1ms")},
+		},
+		failures: 2,
+	}
+	assert.NoError(t, RunTask(ctx, task))
+	assert.Equal(t, 3, task.attempts)
+
+	// runs out of retries
+	task = &flakyTestTask{
+		TaskMeta: TaskMeta{
+			Retries: Int32Pointer(1),
+			Backoff: &Backoff{Interval: StringPointer("1ms")},
+		},
+		failures: 5,
+	}
+	assert.Error(t, RunTask(ctx, task))
+	assert.Equal(t, 2, task.attempts)
+
+	// retryOn condition is false
+	task = &flakyTestTask{
+		TaskMeta: TaskMeta{
+			Retries: Int32Pointer(3),
+			RetryOn: StringPointer("Error contains 'timeout'"),
+		},
+		failures: 5,
+	}
+	assert.Error(t, RunTask(ctx, task))
+	assert.Equal(t, 1, task.attempts)
+
+	// no retries by default
+	task = &flakyTestTask{failures: 1}
+	assert.Error(t, RunTask(ctx, task))
+	assert.Equal(t, 1, task.attempts)
+}
+
+type slowTestTask struct {
+	TaskMeta
+}
+
+func (t *slowTestTask) Run(ctx context.Context) error {
+	return Sleep(ctx, time.Second)
+}
+
+func TestRunWithTimeout(t *testing.T) {
+	ctx := getRetryContext(t)
+
+	task := &slowTestTask{
+		TaskMeta: TaskMeta{
+			Timeout: StringPointer("10ms"),
+		},
+	}
+	err := RunTask(ctx, task)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out")
+
+	// timeout applies to each attempt
+	task.Retries = Int32Pointer(1)
+	task.Backoff = &Backoff{Interval: StringPointer("1ms")}
+	start := time.Now()
+	assert.Error(t, RunTask(ctx, task))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	assert.Error(t, LoadTaskMeta(&slowTestTask{}, &v2alpha2.TaskSpec{
+		With: map[string]apiextensionsv1.JSON{
+			"timeout": {Raw: []byte(`"ten seconds"`)},
+		},
+	}))
+}
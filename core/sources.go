@@ -0,0 +1,147 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// reservedTagLabels are the labels of tags that tag sources cannot use.
+var reservedTagLabels = map[string]bool{
+	"this":   true,
+	"name":   true,
+	"tasks":  true,
+	"secret": true,
+}
+
+// TagSource is a source of non-secret data for the templates of a task: a ConfigMap, or a file mounted into the handler pod.
+// Its data is available in templates under its name, for example, {{ .endpoints.promote }}.
+type TagSource struct {
+	// Name is the label of the data in templates
+	Name string `json:"name" yaml:"name"`
+	// ConfigMap is the [namespace/]name of a ConfigMap; the namespace defaults to that of the experiment
+	ConfigMap *string `json:"configMap,omitempty" yaml:"configMap,omitempty"`
+	// File is the path of a YAML or JSON file, whose top-level fields are the keys,
+	// or of a directory, such as a mounted ConfigMap, whose files are the keys
+	File *string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Keys are the keys to select from the source; all keys are selected if there are none.
+	// Every selected key must be in the source.
+	Keys []string `json:"keys,omitempty" yaml:"keys,omitempty"`
+}
+
+// ValidateTagSources checks that every source has a name that is not reserved, and exactly one of configMap and file.
+func ValidateTagSources(sources []TagSource) error {
+	var errs []error
+	for i, s := range sources {
+		if len(s.Name) == 0 {
+			errs = append(errs, fmt.Errorf("source %d has no name", i))
+		} else if reservedTagLabels[s.Name] {
+			errs = append(errs, fmt.Errorf("source %d has reserved name %s", i, s.Name))
+		}
+		if (s.ConfigMap == nil) == (s.File == nil) {
+			errs = append(errs, fmt.Errorf("source %d needs exactly one of configMap and file", i))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// WithSources adds the data of each source to tags, under the name of the source.
+// A source cannot be named after a tag that is already present, such as a variable of a version, or an earlier source.
+// ConfigMaps are read from the cluster; if ctx holds an object cache, a ConfigMap used by several tasks of an action is read once.
+func (tags Tags) WithSources(ctx context.Context, sources []TagSource) (Tags, error) {
+	if err := ValidateTagSources(sources); err != nil {
+		return tags, err
+	}
+	for _, s := range sources {
+		if _, ok := tags.M[s.Name]; ok {
+			return tags, fmt.Errorf("source %s would replace the tag .%s; choose another name", s.Name, s.Name)
+		}
+		data, err := s.load(ctx)
+		if err != nil {
+			return tags, fmt.Errorf("source %s: %w", s.Name, err)
+		}
+		obj := data
+		if len(s.Keys) > 0 {
+			obj = make(map[string]interface{}, len(s.Keys))
+			for _, k := range s.Keys {
+				v, ok := data[k]
+				if !ok {
+					return tags, fmt.Errorf("source %s: key %s not found", s.Name, k)
+				}
+				obj[k] = v
+			}
+		}
+		tags = tags.With(s.Name, obj)
+	}
+	return tags, nil
+}
+
+// load reads all the keys of the source.
+func (s *TagSource) load(ctx context.Context) (map[string]interface{}, error) {
+	if s.ConfigMap != nil {
+		cm, err := GetConfigMap(ctx, *s.ConfigMap)
+		if err != nil {
+			return nil, err
+		}
+		data := make(map[string]interface{}, len(cm.Data)+len(cm.BinaryData))
+		for k, v := range cm.BinaryData {
+			data[k] = string(v)
+		}
+		for k, v := range cm.Data {
+			data[k] = v
+		}
+		return data, nil
+	}
+	info, err := os.Stat(*s.File)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return readKeyFiles(*s.File)
+	}
+	b, err := ioutil.ReadFile(*s.File)
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]interface{})
+	if err := yaml.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("%s is not a YAML or JSON object: %w", *s.File, err)
+	}
+	return data, nil
+}
+
+// readKeyFiles reads the files in a directory, such as a mounted ConfigMap or Secret, as keys.
+// Hidden files, such as the ..data link of mounted volumes, and subdirectories are skipped.
+func readKeyFiles(dir string) (map[string]interface{}, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	data := make(map[string]interface{}, len(entries))
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		// keys of mounted volumes are links, which are followed
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			continue
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		data[e.Name()] = string(b)
+	}
+	return data, nil
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWithSources(t *testing.T) {
+	defer SetClient(nil)
+	c := getFakeClient(t)
+	SetClient(c)
+	assert.NoError(t, c.Create(context.Background(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "endpoints", Namespace: "iter8"},
+		Data:       map[string]string{"promote": "https://example.com/promote", "rollback": "https://example.com/rollback"},
+		BinaryData: map[string][]byte{"ca": []byte("certificate")},
+	}))
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "channels.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("channels:\n  canary: C123\n"), 0644))
+	// a mounted ConfigMap, with its keys as links to a hidden directory
+	mount := filepath.Join(dir, "mount")
+	assert.NoError(t, os.MkdirAll(filepath.Join(mount, "..data"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(mount, "..data", "manifest"), []byte("kind: Service"), 0644))
+	assert.NoError(t, os.Symlink(filepath.Join("..data", "manifest"), filepath.Join(mount, "manifest")))
+
+	tags, err := NewTags().WithSources(context.Background(), []TagSource{
+		{Name: "endpoints", ConfigMap: StringPointer("iter8/endpoints"), Keys: []string{"promote", "ca"}},
+		{Name: "slack", File: StringPointer(file)},
+		{Name: "promotion", File: StringPointer(mount)},
+	})
+	assert.NoError(t, err)
+	for str, want := range map[string]string{
+		"{{ .endpoints.promote }} {{ .endpoints.ca }}": "https://example.com/promote certificate",
+		"{{ .endpoints.rollback }}":                    "",
+		"{{ .slack.channels.canary }}":                 "C123",
+		"{{ .promotion.manifest }}":                    "kind: Service",
+	} {
+		out, err := tags.Interpolate(&str)
+		assert.NoError(t, err, str)
+		assert.Equal(t, want, out, str)
+	}
+	assert.Len(t, tags.M["promotion"], 1)
+
+	for _, sources := range [][]TagSource{
+		{{Name: "endpoints", ConfigMap: StringPointer("iter8/endpoints"), Keys: []string{"missing"}}},
+		{{Name: "endpoints", ConfigMap: StringPointer("endpoints")}},
+		{{Name: "slack", File: StringPointer(filepath.Join(dir, "missing.yaml"))}},
+		// names of sources are unique
+		{{Name: "slack", File: StringPointer(file)}, {Name: "slack", File: StringPointer(file)}},
+	} {
+		_, err := NewTags().WithSources(context.Background(), sources)
+		assert.Error(t, err)
+	}
+
+	// sources cannot replace existing tags, such as variables of versions
+	_, err = NewTags().With("revision", "revision1").WithSources(context.Background(), []TagSource{{Name: "revision", File: StringPointer(file)}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "would replace the tag .revision")
+}
+
+func TestValidateTagSources(t *testing.T) {
+	assert.NoError(t, ValidateTagSources(nil))
+	assert.NoError(t, ValidateTagSources([]TagSource{{Name: "endpoints", ConfigMap: StringPointer("endpoints")}}))
+	err := ValidateTagSources([]TagSource{
+		{ConfigMap: StringPointer("endpoints")},
+		{Name: "secret", File: StringPointer("/etc/secret")},
+		{Name: "both", ConfigMap: StringPointer("endpoints"), File: StringPointer("/etc/endpoints")},
+		{Name: "neither"},
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "source 0 has no name")
+	assert.Contains(t, err.Error(), "source 1 has reserved name secret")
+	assert.Contains(t, err.Error(), "source 2 needs exactly one of configMap and file")
+	assert.Contains(t, err.Error(), "source 3 needs exactly one of configMap and file")
+}
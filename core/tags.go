@@ -2,8 +2,8 @@ package core
 
 import (
 	"bytes"
-	"errors"
-	"html/template"
+	"context"
+	"fmt"
 
 	"github.com/iter8-tools/etc3/api/v2alpha2"
 	corev1 "k8s.io/api/core/v1"
@@ -12,6 +12,12 @@ import (
 // Tags supports string extrapolation using tags.
 type Tags struct {
 	M map[string]interface{}
+	// exp is the experiment whose methods templates can call, if any
+	exp *Experiment
+	// versions is the versionInfo of the task, if any
+	versions []VersionInfo
+	// ctx is the context in which template functions read from the cluster, if any
+	ctx context.Context
 }
 
 // NewTags creates an empty instance of Tags
@@ -31,6 +37,19 @@ func (tags Tags) WithSecret(label string, secret *corev1.Secret) Tags {
 	return tags
 }
 
+// WithMaskedSecret adds the keys of secret to tags under label, each with the value RedactedValue.
+// Plans interpolate templates with masked secrets, so that they show where secret values go without revealing them.
+func (tags Tags) WithMaskedSecret(label string, secret *corev1.Secret) Tags {
+	if secret != nil {
+		obj := make(map[string]interface{})
+		for n := range secret.Data {
+			obj[n] = RedactedValue
+		}
+		tags = tags.With(label, obj)
+	}
+	return tags
+}
+
 // With adds obj to tags
 func (tags Tags) With(label string, obj interface{}) Tags {
 	if obj != nil {
@@ -133,20 +152,25 @@ func (tags Tags) WithRecommendedVersionForPromotion(exp *v2alpha2.Experiment, ve
 }
 
 // Interpolate str using tags.
+// str is a text/template, which is executed over tags.M with the template functions of the handler. Missing values are rendered as empty strings.
 func (tags *Tags) Interpolate(str *string) (string, error) {
 	if tags == nil || tags.M == nil { // return a copy of the string
 		return *str, nil
 	}
-	var err error
-	var templ *template.Template
-	if templ, err = template.New("").Parse(*str); err == nil {
-		buf := bytes.Buffer{}
-		if err = templ.Execute(&buf, tags.M); err == nil {
-			return buf.String(), nil
-		}
+	return tags.interpolate(*str, "", "")
+}
+
+// interpolate str using tags, with the given action delimiters; empty delimiters stand for the default {{ and }}.
+func (tags *Tags) interpolate(str string, left string, right string) (string, error) {
+	templ, err := tags.parseTemplate(str, left, right)
+	if err != nil {
+		log.Error("template creation error: ", err)
+		return "", fmt.Errorf("cannot interpolate string: %w", err)
+	}
+	buf := bytes.Buffer{}
+	if err = templ.Execute(&buf, tags.M); err != nil {
 		log.Error("template execution error: ", err)
-		return "", errors.New("cannot interpolate string")
+		return "", fmt.Errorf("cannot interpolate string: %w", err)
 	}
-	log.Error("template creation error: ", err)
-	return "", errors.New("cannot interpolate string")
+	return buf.String(), nil
 }
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/iter8-tools/etc3/api/v2alpha2"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// TaskGroup holds the tasks of a task group, such as common/finally or common/parallel, and does what task groups share:
+// making, validating and planning their tasks. Task groups embed it, and run its tasks in their own way.
+type TaskGroup struct {
+	// kind identifies the tasks of the group in errors and plans, for example, finally
+	kind  string
+	tasks []Task
+}
+
+// MakeTaskGroup decodes the spec of the named task group into task, a pointer to the struct of the group, and makes the task and run specs in its tasks input.
+// Returns error if the spec is not that of the named group, or if the group has no tasks or a task cannot be made.
+func MakeTaskGroup(t *v2alpha2.TaskSpec, name string, task interface{}) (TaskGroup, error) {
+	if t.Task == nil || *t.Task != name {
+		return TaskGroup{}, fmt.Errorf("task need to be '%s'", name)
+	}
+	jsonBytes, err := json.Marshal(t)
+	if err != nil {
+		return TaskGroup{}, err
+	}
+	if err = json.Unmarshal(jsonBytes, task); err != nil {
+		return TaskGroup{}, err
+	}
+
+	g := TaskGroup{kind: path.Base(name)}
+	specs := []v2alpha2.TaskSpec{}
+	if raw, ok := t.With[groupTasksKey]; ok {
+		if err = json.Unmarshal(raw.Raw, &specs); err != nil {
+			return TaskGroup{}, err
+		}
+	}
+	if len(specs) == 0 {
+		return TaskGroup{}, fmt.Errorf("%s task with no tasks", g.kind)
+	}
+	g.tasks = make([]Task, len(specs))
+	for i := range specs {
+		if g.tasks[i], err = MakeTaskOrRun(&specs[i]); err != nil {
+			return TaskGroup{}, fmt.Errorf("task %d in %s group: %w", i, g.kind, err)
+		}
+	}
+	return g, nil
+}
+
+// Tasks returns the tasks of the group, in order.
+func (g *TaskGroup) Tasks() []Task {
+	return g.tasks
+}
+
+// Validate validates the tasks in the group.
+func (g *TaskGroup) Validate(exp *Experiment) error {
+	var errs []error
+	for i := range g.tasks {
+		if agg := ValidateTask(exp, g.tasks[i]); agg != nil {
+			for _, err := range agg.Errors() {
+				errs = append(errs, fmt.Errorf("%s task %d: %w", g.kind, i, err))
+			}
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// PlanTasks writes the plans of the tasks in the group to w, without running them.
+func (g *TaskGroup) PlanTasks(ctx context.Context, w io.Writer) error {
+	errs := make([]error, len(g.tasks))
+	for i := range g.tasks {
+		fmt.Fprintf(w, "------ %s task %d: %s\n", g.kind, i, GetTaskName(g.tasks[i]))
+		if err := PlanTask(ctx, g.tasks[i], w); err != nil {
+			errs[i] = fmt.Errorf("%s task %d: %w", g.kind, i, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
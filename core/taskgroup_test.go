@@ -0,0 +1,51 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/iter8-tools/etc3/api/v2alpha2"
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func TestMakeTaskGroup(t *testing.T) {
+	MustRegisterTask("test/member", makeTestTask)
+	spec := &v2alpha2.TaskSpec{
+		Task: StringPointer("test/taskgroup"),
+		If:   StringPointer("WinnerFound()"),
+		With: map[string]apiextensionsv1.JSON{
+			"tasks": {Raw: []byte(`[{"task": "test/member"}, {"task": "test/member", "if": "CandidateWon()"}]`)},
+		},
+	}
+	meta := struct {
+		TaskMeta `json:",inline"`
+	}{}
+	g, err := MakeTaskGroup(spec, "test/taskgroup", &meta)
+	assert.NoError(t, err)
+	assert.Len(t, g.Tasks(), 2)
+	// the spec of the group is decoded into its task
+	assert.Equal(t, "WinnerFound()", *meta.If)
+
+	ctx := getRetryContext(t)
+	exp, _ := GetExperimentFromContext(ctx)
+	assert.NoError(t, g.Validate(exp))
+
+	var plan bytes.Buffer
+	assert.NoError(t, g.PlanTasks(ctx, &plan))
+	assert.Contains(t, plan.String(), "------ taskgroup task 0: test/member")
+	assert.Contains(t, plan.String(), "------ taskgroup task 1: test/member")
+
+	for raw, msg := range map[string]string{
+		`[]`:                        "taskgroup task with no tasks",
+		`[{"task": "test/absent"}]`: "task 0 in taskgroup group",
+	} {
+		spec.With["tasks"] = apiextensionsv1.JSON{Raw: []byte(raw)}
+		_, err = MakeTaskGroup(spec, "test/taskgroup", &meta)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), msg)
+		}
+	}
+	_, err = MakeTaskGroup(spec, "test/other", &meta)
+	assert.Error(t, err)
+}
@@ -0,0 +1,186 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"text/template"
+	"text/template/parse"
+)
+
+// printValueFunc is the name of the function that parseTemplate appends to the pipeline of every action that prints a value.
+// It renders missing values, which text/template prints as <no value>, as empty strings, as the handler always did.
+const printValueFunc = "iter8PrintValue"
+
+// experimentMethods are the methods of the experiment that templates can call as fields, for example, {{ .WinnerFound }}.
+// This keeps templates of run specs, which used to be executed over the experiment itself, working.
+var experimentMethods = []string{
+	"WinnerFound",
+	"CandidateWon",
+	"GetVersionRecommendedForPromotion",
+	"GetVersionDetail",
+	"GetActionOutcome",
+	"ActionSucceeded",
+	"ActionFailedTask",
+}
+
+// GetTemplateTags returns the tags with which the templates of tasks are interpolated. Every task uses the same data model and functions.
+// .this is the experiment as an unstructured object, for example, {{ .this.metadata.name }}.
+// .name and .<variable> are the name and variables of the version recommended for promotion, from versions if given, else from the experiment.
+// .tasks are the outputs of earlier tasks, for example, {{ .tasks.build.outputs.digest }}.
+// Tasks that read a secret add its data with tags.WithSecret("secret", secret), as .secret.<key> or {{ .Secret "key" }}.
+// For compatibility with run specs, the exported fields of the experiment, such as .Name and .Namespace, are also available,
+// and methods such as .WinnerFound can be called as fields.
+func GetTemplateTags(ctx context.Context, versions []VersionInfo) (*Tags, error) {
+	exp, err := GetExperimentFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := exp.ToMap()
+	if err != nil {
+		return nil, err
+	}
+	tags := NewTags().
+		With("this", obj).
+		WithRecommendedVersionForPromotion(&exp.Experiment, versions).
+		WithOutputs(ctx)
+	tags.exp, tags.versions, tags.ctx = exp, versions, ctx
+	addToEnv(tags.M, reflect.ValueOf(exp), false)
+	return &tags, nil
+}
+
+// funcs returns the functions available in templates interpolated with tags: the library functions, and the functions of run specs.
+func (tags *Tags) funcs() template.FuncMap {
+	var exp *Experiment
+	var m map[string]interface{}
+	if tags != nil {
+		exp, m = tags.exp, tags.M
+	}
+	funcs := tags.libraryFuncs()
+	// Secret returns the value of a key in the secret of the task
+	funcs["Secret"] = func(key string) (string, error) {
+		secret, ok := m["secret"].(map[string]interface{})
+		if !ok {
+			return "", errors.New("no secret specified")
+		}
+		val, ok := secret[key]
+		if !ok {
+			return "", fmt.Errorf("specified key %s seems absent in secret", key)
+		}
+		return fmt.Sprint(val), nil
+	}
+	v := reflect.ValueOf(exp)
+	for _, name := range experimentMethods {
+		funcs[name] = v.MethodByName(name).Interface()
+	}
+	funcs[printValueFunc] = func(v interface{}) interface{} {
+		if v == nil {
+			return ""
+		}
+		return v
+	}
+	return funcs
+}
+
+// parseTemplate parses str as a template with the functions of tags, and the given action delimiters; empty delimiters stand for the default {{ and }}.
+// Fields of the form .Secret or .WinnerFound, which are not in tags, are rewritten into calls to the functions of the same name.
+// A key that is missing from tags is passed to functions as nil, so that default and required apply to it, and is printed as an empty string.
+func (tags *Tags) parseTemplate(str string, left string, right string) (*template.Template, error) {
+	funcs := tags.funcs()
+	templ, err := template.New("").Delims(left, right).Funcs(funcs).Parse(str)
+	if err != nil {
+		return nil, err
+	}
+	isCompatFunc := func(name string) bool {
+		if _, ok := funcs[name]; !ok {
+			return false
+		}
+		return tags == nil || !inEnv(tags.M, name)
+	}
+	for _, t := range templ.Templates() {
+		if t.Tree != nil {
+			rewriteFields(t.Tree, t.Tree.Root, isCompatFunc)
+			wrapPrints(t.Tree, t.Tree.Root)
+		}
+	}
+	return templ, nil
+}
+
+// rewriteFields replaces fields with a single name for which isFunc is true, in the arguments of commands under node, by function calls.
+func rewriteFields(tree *parse.Tree, node parse.Node, isFunc func(string) bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			rewriteFields(tree, c, isFunc)
+		}
+	case *parse.ActionNode:
+		rewriteFields(tree, n.Pipe, isFunc)
+	case *parse.IfNode:
+		rewriteBranch(tree, &n.BranchNode, isFunc)
+	case *parse.RangeNode:
+		rewriteBranch(tree, &n.BranchNode, isFunc)
+	case *parse.WithNode:
+		rewriteBranch(tree, &n.BranchNode, isFunc)
+	case *parse.TemplateNode:
+		rewriteFields(tree, n.Pipe, isFunc)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			rewriteFields(tree, c, isFunc)
+		}
+	case *parse.ChainNode:
+		rewriteFields(tree, n.Node, isFunc)
+	case *parse.CommandNode:
+		for i, arg := range n.Args {
+			if f, ok := arg.(*parse.FieldNode); ok && len(f.Ident) == 1 && isFunc(f.Ident[0]) {
+				n.Args[i] = parse.NewIdentifier(f.Ident[0]).SetTree(tree).SetPos(f.Pos)
+				continue
+			}
+			rewriteFields(tree, arg, isFunc)
+		}
+	}
+}
+
+// rewriteBranch rewrites the fields in the pipeline and lists of an if, range or with node.
+func rewriteBranch(tree *parse.Tree, n *parse.BranchNode, isFunc func(string) bool) {
+	rewriteFields(tree, n.Pipe, isFunc)
+	rewriteFields(tree, n.List, isFunc)
+	rewriteFields(tree, n.ElseList, isFunc)
+}
+
+// wrapPrints appends a call to the print value function to the pipeline of every action under node that prints a value.
+func wrapPrints(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			wrapPrints(tree, c)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(printValueFunc).SetTree(tree).SetPos(n.Pos)},
+		})
+	case *parse.IfNode:
+		wrapPrints(tree, n.List)
+		wrapPrints(tree, n.ElseList)
+	case *parse.RangeNode:
+		wrapPrints(tree, n.List)
+		wrapPrints(tree, n.ElseList)
+	case *parse.WithNode:
+		wrapPrints(tree, n.List)
+		wrapPrints(tree, n.ElseList)
+	}
+}
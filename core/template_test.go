@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestGetTemplateTags(t *testing.T) {
+	_, err := GetTemplateTags(context.Background(), nil)
+	assert.Error(t, err)
+
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/experiment6.yaml")).Build()
+	assert.NoError(t, err)
+	ctx := ContextWithOutputs(context.WithValue(context.Background(), ContextKey("experiment"), exp))
+	SetOutput(contextWithTaskID(ctx, "build"), "digest", "sha256:abc")
+	tags, err := GetTemplateTags(ctx, nil)
+	assert.NoError(t, err)
+	*tags = tags.WithSecret("secret", &corev1.Secret{Data: map[string][]byte{"token": []byte("xoxb")}})
+
+	for str, want := range map[string]string{
+		// the data model of every task
+		"{{ .this.metadata.name }}":         "sklearn-iris-experiment-6",
+		"{{ .name }} {{ .revision }}":       "default revision1",
+		"{{ .tasks.build.outputs.digest }}": "sha256:abc",
+		"{{ .secret.token }}":               "xoxb",
+		// forms of run specs
+		"{{ .Namespace }}/{{ .Name }}":                         "default/sklearn-iris-experiment-6",
+		`{{ .Secret "token" }}`:                                "xoxb",
+		"{{ .Spec.Target }}":                                   "default/sklearn-iris",
+		"{{ if .WinnerFound }}won{{ else }}no winner{{ end }}": "no winner",
+		"{{ .GetVersionRecommendedForPromotion }}":             "default",
+		// no HTML escaping
+		`{"name": "{{ .name }}", "cmd": "a && b < c"}`: `{"name": "default", "cmd": "a && b < c"}`,
+		// missing values are empty
+		"hello {{ .omg }} world":                "hello  world",
+		"{{ .this.status.omg.omg }}":            "",
+		`{{ range .tasks }}{{ .omg }}{{ end }}`: "",
+	} {
+		out, err := tags.Interpolate(&str)
+		assert.NoError(t, err, str)
+		assert.Equal(t, want, out, str)
+	}
+
+	// only missing values are rendered as empty strings, not the text <no value>
+	str := "literal <no value> is kept"
+	out, err := tags.Interpolate(&str)
+	assert.NoError(t, err)
+	assert.Equal(t, str, out)
+
+	// errors of compatibility functions are returned
+	str = `{{ .Secret "missing" }}`
+	_, err = tags.Interpolate(&str)
+	assert.Error(t, err)
+	noSecret, err := GetTemplateTags(ctx, nil)
+	assert.NoError(t, err)
+	str = `{{ .Secret "token" }}`
+	_, err = noSecret.Interpolate(&str)
+	assert.Error(t, err)
+}
@@ -0,0 +1,228 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ghodss/yaml"
+)
+
+// libraryFuncs returns the library of functions available in the templates of every task, in addition to the built-in functions of text/template.
+// Functions that read the experiment or the cluster use the experiment and context of tags, if any.
+func (tags *Tags) libraryFuncs() template.FuncMap {
+	return template.FuncMap{
+		// toJson encodes a value as JSON, for example, {{ .tasks.collect.outputs.summary | toJson }}
+		"toJson": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		// toYaml encodes a value as YAML, without a trailing newline
+		"toYaml": func(v interface{}) (string, error) {
+			b, err := yaml.Marshal(v)
+			return strings.TrimSuffix(string(b), "\n"), err
+		},
+		// indent prefixes every line of s with n spaces
+		"indent": func(n int, s string) string {
+			pad := strings.Repeat(" ", n)
+			return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+		},
+		// quote returns a double-quoted string, with Go escapes, which is also a valid JSON string for printable text
+		"quote": func(v interface{}) string {
+			return strconv.Quote(toString(v))
+		},
+		// shellQuote returns a single-quoted string, which a shell reads as one word, as is
+		"shellQuote": func(v interface{}) string {
+			return "'" + strings.ReplaceAll(toString(v), "'", `'\''`) + "'"
+		},
+		// b64enc encodes a value with standard base64 encoding
+		"b64enc": func(v interface{}) string {
+			return base64.StdEncoding.EncodeToString([]byte(toString(v)))
+		},
+		// default returns d if v is empty, for example, {{ .replicas | default 1 }}
+		"default": func(d interface{}, v interface{}) interface{} {
+			if isEmpty(v) {
+				return d
+			}
+			return v
+		},
+		// required fails the interpolation with msg if v is empty
+		"required": func(msg string, v interface{}) (interface{}, error) {
+			if isEmpty(v) {
+				return nil, errors.New(msg)
+			}
+			return v, nil
+		},
+		// now is the current time, for example, {{ now.UTC.Format "2006-01-02T15:04:05Z" }}
+		"now": time.Now,
+		// duration parses a duration such as 30s or 1h15m
+		"duration": time.ParseDuration,
+		// lookupSecret returns the value of a key in a [namespace/]name secret
+		"lookupSecret": func(name string, key string) (string, error) {
+			secret, err := GetSecret(tags.context(), name)
+			if err != nil {
+				return "", err
+			}
+			val, ok := secret.Data[key]
+			if !ok {
+				return "", fmt.Errorf("key %s not found in secret %s", key, name)
+			}
+			return string(val), nil
+		},
+		// lookupConfigMap returns the value of a key in a [namespace/]name ConfigMap
+		"lookupConfigMap": func(name string, key string) (string, error) {
+			cm, err := GetConfigMap(tags.context(), name)
+			if err != nil {
+				return "", err
+			}
+			if val, ok := cm.Data[key]; ok {
+				return val, nil
+			}
+			if val, ok := cm.BinaryData[key]; ok {
+				return string(val), nil
+			}
+			return "", fmt.Errorf("key %s not found in configmap %s", key, name)
+		},
+		// env is the value of an environment variable, or empty if it is not set
+		"env": os.Getenv,
+		// versionVar is the value of a variable of a version, for example, {{ versionVar "canary" "revision" }}
+		"versionVar": tags.versionVar,
+		// percentile is a latency percentile of a version in seconds, from the builtin histograms of metrics/collect, for example, {{ percentile "canary" 95 }}
+		"percentile": func(version string, p float64) (float64, error) {
+			h, err := tags.builtinHist(version)
+			if err != nil {
+				return 0, err
+			}
+			return h.percentile(p)
+		},
+		// mean is the mean latency of a version in seconds, from the builtin histograms of metrics/collect
+		"mean": func(version string) (float64, error) {
+			h, err := tags.builtinHist(version)
+			if err != nil {
+				return 0, err
+			}
+			if h.DurationHistogram.Count == 0 {
+				return 0, fmt.Errorf("no requests to version %s", version)
+			}
+			return h.DurationHistogram.Sum / float64(h.DurationHistogram.Count), nil
+		},
+	}
+}
+
+// context returns the context in which template functions read from the cluster.
+func (tags *Tags) context() context.Context {
+	if tags == nil || tags.ctx == nil {
+		return context.Background()
+	}
+	return tags.ctx
+}
+
+// versionVar returns the value of the named variable of the version, or empty if there is none.
+// The versionInfo of the task, if any, takes precedence over the variables in the experiment; its entries match the versions of the experiment by position.
+func (tags *Tags) versionVar(version string, name string) string {
+	if tags == nil {
+		return ""
+	}
+	for i, v := range tags.exp.versionNames() {
+		if v != version || i >= len(tags.versions) {
+			continue
+		}
+		for _, nv := range tags.versions[i].Variables {
+			if nv.Name == name {
+				return nv.Value
+			}
+		}
+	}
+	return tags.exp.variable(version, name)
+}
+
+// builtinHist is the latency histogram of a version in the aggregated builtin histograms of metrics/collect.
+// Durations are in seconds.
+type builtinHist struct {
+	DurationHistogram struct {
+		Count int
+		Max   float64
+		Sum   float64
+		Data  []struct {
+			Start float64
+			End   float64
+			Count int
+		}
+	}
+}
+
+// builtinHist returns the histogram of the version from the status of the experiment.
+func (tags *Tags) builtinHist(version string) (*builtinHist, error) {
+	if tags == nil || tags.exp == nil || tags.exp.Status.Analysis == nil || tags.exp.Status.Analysis.AggregatedBuiltinHists == nil {
+		return nil, errors.New("no builtin histograms in experiment")
+	}
+	hists := map[string]*builtinHist{}
+	if err := json.Unmarshal(tags.exp.Status.Analysis.AggregatedBuiltinHists.Data.Raw, &hists); err != nil {
+		return nil, fmt.Errorf("cannot read builtin histograms: %w", err)
+	}
+	h, ok := hists[version]
+	if !ok || h == nil {
+		return nil, fmt.Errorf("no builtin histogram for version %s", version)
+	}
+	return h, nil
+}
+
+// percentile returns the p-th percentile of the histogram, interpolated linearly within its buckets.
+func (h *builtinHist) percentile(p float64) (float64, error) {
+	if p < 0 || p > 100 {
+		return 0, fmt.Errorf("percentile %v is not between 0 and 100", p)
+	}
+	buckets := h.DurationHistogram.Data
+	total := 0
+	for _, b := range buckets {
+		total += b.Count
+	}
+	if total == 0 {
+		return 0, errors.New("no requests in histogram")
+	}
+	// histograms aggregated over several runs hold overlapping buckets
+	sort.SliceStable(buckets, func(i, j int) bool { return buckets[i].Start < buckets[j].Start })
+	target := p / 100 * float64(total)
+	seen := 0.0
+	for _, b := range buckets {
+		if b.Count == 0 {
+			continue
+		}
+		if seen+float64(b.Count) >= target {
+			return b.Start + (b.End-b.Start)*(target-seen)/float64(b.Count), nil
+		}
+		seen += float64(b.Count)
+	}
+	return h.DurationHistogram.Max, nil
+}
+
+// toString formats v as a string; nil is formatted as an empty string.
+func toString(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// isEmpty determines if v is nil, a zero value, or an empty string, slice or map.
+func isEmpty(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return true
+	}
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	default:
+		return rv.IsZero()
+	}
+}
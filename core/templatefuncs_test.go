@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/iter8-tools/etc3/api/v2alpha2"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	defer SetClient(nil)
+	c := getFakeClient(t)
+	SetClient(c)
+	assert.NoError(t, c.Create(context.Background(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "slack", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("xoxb")},
+	}))
+	assert.NoError(t, c.Create(context.Background(), &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "endpoints", Namespace: "default"},
+		Data:       map[string]string{"promote": "https://example.com/promote"},
+	}))
+
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/experiment6.yaml")).Build()
+	assert.NoError(t, err)
+	exp.SetAggregatedBuiltinHists(v1.JSON{Raw: []byte(`{
+		"canary": {"DurationHistogram": {"Count": 10, "Max": 0.4, "Sum": 2, "Data": [
+			{"Start": 0.2, "End": 0.4, "Count": 5},
+			{"Start": 0, "End": 0.2, "Count": 5}
+		]}}
+	}`)})
+	ctx := ContextWithOutputs(context.WithValue(context.Background(), ContextKey("experiment"), exp))
+	SetOutput(contextWithTaskID(ctx, "build"), "labels", map[string]string{"app": "iris"})
+	os.Setenv("ITER8_TEMPLATE_TEST", "on")
+	defer os.Unsetenv("ITER8_TEMPLATE_TEST")
+	tags, err := GetTemplateTags(ctx, []VersionInfo{{}, {Variables: []v2alpha2.NamedValue{{Name: "revision", Value: "from-task"}}}})
+	assert.NoError(t, err)
+
+	for str, want := range map[string]string{
+		"{{ .tasks.build.outputs.labels | toJson }}":                     `{"app":"iris"}`,
+		"labels:\n{{ .tasks.build.outputs.labels | toYaml | indent 2 }}": "labels:\n  app: iris",
+		`{{ "say \"hi\"" | quote }}`:                                     `"say \"hi\""`,
+		`{{ "it's" | shellQuote }}`:                                      `'it'\''s'`,
+		`{{ "user:pass" | b64enc }}`:                                     "dXNlcjpwYXNz",
+		`{{ .missing | default "none" }} {{ .name | default "none" }}`:   "none default",
+		`{{ .missing.nested | default "none" }}`:                         "none",
+		`{{ required "name is required" .name }}`:                        "default",
+		`{{ (duration "1m30s").Seconds }}`:                               "90",
+		`{{ if now.IsZero }}zero{{ else }}now{{ end }}`:                  "now",
+		`{{ lookupSecret "default/slack" "token" }}`:                     "xoxb",
+		`{{ lookupConfigMap "default/endpoints" "promote" }}`:            "https://example.com/promote",
+		`{{ env "ITER8_TEMPLATE_TEST" }}`:                                "on",
+		`{{ versionVar "default" "revision" }}`:                          "revision1",
+		`{{ versionVar "canary" "revision" }}`:                           "from-task",
+		`{{ versionVar "canary" "missing" }}`:                            "",
+		`{{ percentile "canary" 50 }} {{ percentile "canary" 75 }}`:      "0.2 0.30000000000000004",
+		`{{ mean "canary" }}`:                                            "0.2",
+	} {
+		out, err := tags.Interpolate(&str)
+		assert.NoError(t, err, str)
+		assert.Equal(t, want, out, str)
+	}
+
+	// required fails with its message on a missing field
+	str := `{{ required "name is required" .missing }}`
+	_, err = tags.Interpolate(&str)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "name is required")
+	}
+
+	for _, str := range []string{
+		`{{ lookupSecret "default/slack" "missing" }}`,
+		`{{ lookupConfigMap "default/missing" "promote" }}`,
+		`{{ percentile "default" 50 }}`,
+		`{{ percentile "canary" 101 }}`,
+		`{{ duration "soon" }}`,
+	} {
+		_, err := tags.Interpolate(&str)
+		assert.Error(t, err, str)
+	}
+}
+
+func TestBuiltinHistPercentile(t *testing.T) {
+	h := &builtinHist{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"DurationHistogram": {"Count": 4, "Max": 0.3, "Sum": 0.6, "Data": [
+		{"Start": 0.1, "End": 0.2, "Count": 2},
+		{"Start": 0.2, "End": 0.3, "Count": 2}
+	]}}`), h))
+	for p, want := range map[float64]float64{0: 0.1, 25: 0.15, 50: 0.2, 100: 0.3} {
+		got, err := h.percentile(p)
+		assert.NoError(t, err)
+		assert.InDelta(t, want, got, 1e-9, p)
+	}
+	_, err := (&builtinHist{}).percentile(50)
+	assert.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the tracer that creates action and task spans; it is also the service name of the handler.
+const TracerName string = "iter8-handler"
+
+// span attributes
+const (
+	experimentAttribute     = attribute.Key("iter8.experiment")
+	actionAttribute         = attribute.Key("iter8.action")
+	taskNameAttribute       = attribute.Key("iter8.task.name")
+	taskIndexAttribute      = attribute.Key("iter8.task.index")
+	taskIncludeAttribute    = attribute.Key("iter8.task.include")
+	taskAttemptsAttribute   = attribute.Key("iter8.task.attempts")
+	taskSkipReasonAttribute = attribute.Key("iter8.task.skipReason")
+)
+
+// InitTracing exports spans to the OTLP/HTTP endpoint, for example, http://otel-collector:4318, and propagates trace context in W3C traceparent headers.
+// Until InitTracing is called, spans are not recorded and trace context is not propagated.
+// Returns a function that flushes pending spans and shuts down the exporter.
+func InitTracing(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if len(u.Host) == 0 {
+		return nil, errors.New("OTLP endpoint needs to be a URL with a host: " + endpoint)
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(u.Host)}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(u.Path) > 0 && u.Path != "/" {
+		opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(TracerName))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return tp.Shutdown, nil
+}
+
+// StartActionSpan starts the span of an action of the experiment. Spans of the tasks run by the action are children of this span.
+func StartActionSpan(ctx context.Context, exp *Experiment, action string) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, "action "+action, trace.WithAttributes(
+		experimentAttribute.String(exp.Namespace+"/"+exp.Name),
+		actionAttribute.String(action),
+	))
+}
+
+// EndSpan records the error, if any, in the span, and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startTaskSpan starts the span of a task. The location of the task in the spec of the action is recorded for tasks run by Action.Run.
+func startTaskSpan(ctx context.Context, t Task, run *taskRun) (context.Context, trace.Span) {
+	name := GetTaskName(t)
+	attrs := []attribute.KeyValue{taskNameAttribute.String(name)}
+	if run != nil {
+		l := taskLocation(run.index, t)
+		attrs = append(attrs, taskIndexAttribute.Int(l.Index))
+		if len(l.Include) > 0 {
+			attrs = append(attrs, taskIncludeAttribute.String(l.Include))
+		}
+	}
+	return otel.Tracer(TracerName).Start(ctx, "task "+name, trace.WithAttributes(attrs...))
+}
+
+// TraceHeaders returns the headers that propagate the trace context of ctx, if any, to a request sent by a task.
+func TraceHeaders(ctx context.Context) map[string]string {
+	h := http.Header{}
+	InjectTraceHeaders(ctx, h)
+	headers := make(map[string]string, len(h))
+	for name := range h {
+		headers[name] = h.Get(name)
+	}
+	return headers
+}
+
+// InjectTraceHeaders adds the headers that propagate the trace context of ctx, if any, to h.
+func InjectTraceHeaders(ctx context.Context, h http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(h))
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// headerTestTask records the trace headers that it would propagate in its requests.
+type headerTestTask struct {
+	TaskMeta
+	headers map[string]string
+}
+
+func (t *headerTestTask) Run(ctx context.Context) error {
+	t.headers = TraceHeaders(ctx)
+	return nil
+}
+
+func TestTracing(t *testing.T) {
+	// the server stands in for an OTLP collector
+	var lock sync.Mutex
+	var spans []*tracepb.Span
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		body, _ := ioutil.ReadAll(r.Body)
+		req := &collectortrace.ExportTraceServiceRequest{}
+		assert.NoError(t, proto.Unmarshal(body, req))
+		lock.Lock()
+		defer lock.Unlock()
+		for _, rs := range req.ResourceSpans {
+			for _, ils := range rs.InstrumentationLibrarySpans {
+				spans = append(spans, ils.Spans...)
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	defer func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	}()
+
+	// without tracing, no trace context is propagated
+	assert.Empty(t, TraceHeaders(context.Background()))
+
+	shutdown, err := InitTracing(context.Background(), server.URL)
+	assert.NoError(t, err)
+
+	ctx := getRetryContext(t)
+	exp, err := GetExperimentFromContext(ctx)
+	assert.NoError(t, err)
+	ctx, span := StartActionSpan(ctx, exp, "start")
+	good := &headerTestTask{TaskMeta: TaskMeta{Task: StringPointer("test/good")}}
+	action := Action{
+		good,
+		&badTestTask{TaskMeta: TaskMeta{Task: StringPointer("test/bad")}},
+		&testTask{TaskMeta: TaskMeta{Task: StringPointer("test/skipped")}},
+	}
+	err = action.Run(ctx)
+	assert.Error(t, err)
+	EndSpan(span, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	// the task propagates the trace context of its own span
+	assert.Contains(t, good.headers, "Traceparent")
+	assert.Contains(t, good.headers["Traceparent"], span.SpanContext().TraceID().String())
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Len(t, spans, 4)
+	byName := map[string]*tracepb.Span{}
+	for _, s := range spans {
+		byName[s.Name] = s
+		traceID := span.SpanContext().TraceID()
+		assert.Equal(t, traceID[:], s.TraceId)
+	}
+	assert.Contains(t, byName, "action start")
+	assert.Equal(t, tracepb.Status_STATUS_CODE_ERROR, byName["action start"].Status.Code)
+	for i, name := range []string{"task test/good", "task test/bad", "task test/skipped"} {
+		assert.Contains(t, byName, name)
+		assert.Equal(t, byName["action start"].SpanId, byName[name].ParentSpanId)
+		attrs := map[string]interface{}{}
+		for _, kv := range byName[name].Attributes {
+			attrs[kv.Key] = kv.Value.GetStringValue()
+			if kv.Key == string(taskIndexAttribute) {
+				attrs[kv.Key] = kv.Value.GetIntValue()
+			}
+		}
+		assert.Equal(t, int64(i), attrs[string(taskIndexAttribute)])
+	}
+	assert.Equal(t, tracepb.Status_STATUS_CODE_ERROR, byName["task test/bad"].Status.Code)
+	assert.NotEqual(t, tracepb.Status_STATUS_CODE_ERROR, byName["task test/good"].Status.Code)
+
+	_, err = InitTracing(context.Background(), "localhost")
+	assert.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"io/ioutil"
 	"net/http"
@@ -25,13 +26,14 @@ func SetLogLevel(l logrus.Level) {
 }
 
 // GetLogger returns a logger, if needed after creating it.
+// Values registered with RegisterSensitive, such as the data of secrets, are masked in its log lines.
 func GetLogger() *logrus.Logger {
 	if log == nil {
 		log = logrus.New()
 		log.SetLevel(logLevel)
-		log.SetFormatter(&logrus.TextFormatter{
+		log.SetFormatter(&redactingFormatter{&logrus.TextFormatter{
 			DisableQuote: true,
-		})
+		}})
 	}
 	return log
 }
@@ -103,6 +105,42 @@ func WaitTimeoutOrError(wg *sync.WaitGroup, timeout time.Duration, errCh chan er
 	}
 }
 
+// Sleep pauses for the given duration, or until ctx is done, whichever happens first.
+// Returns the context error if ctx is done before the duration elapses.
+func Sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// WaitContextOrError waits for one of the following three events
+// 1) all goroutines in the waitgroup to finish -- no error is returned unless one was sent on errCh
+// 2) ctx is done before all go routines could finish normally -- the context error is returned
+// 3) an error in the errCh channel sent by one of the goroutines -- an error is returned
+func WaitContextOrError(ctx context.Context, wg *sync.WaitGroup, errCh chan error) error {
+	c := make(chan struct{})
+	go func() {
+		defer close(c)
+		wg.Wait()
+	}()
+	select {
+	case <-c: // completed; errCh may be buffered, so check it for errors sent before completion
+		select {
+		case err := <-errCh:
+			return err
+		default:
+			return nil
+		}
+	case <-ctx.Done(): // deadline exceeded or cancelled
+		return ctx.Err()
+	case err := <-errCh: // error in channel
+		return err
+	}
+}
+
 // GetJSONBytes downloads JSON from URL and returns a byte slice
 func GetJSONBytes(url string) ([]byte, error) {
 	var myClient = &http.Client{Timeout: 10 * time.Second}
@@ -28,7 +28,7 @@ var _ = Describe("GetSecretToken", func() {
 			Expect(k8sClient.Create(context.Background(), &secret)).To(Succeed())
 
 			By("fetching secret from cluster secret")
-			s, err := GetSecret("default/mysecret")
+			s, err := GetSecret(context.Background(), "default/mysecret")
 			Expect(err).ToNot(HaveOccurred())
 			Expect(s).ToNot(BeNil())
 			token, err := GetTokenFromSecret(s)
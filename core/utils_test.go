@@ -1,6 +1,8 @@
 package core
 
 import (
+	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -63,6 +65,46 @@ func TestWaitTimeout(t *testing.T) {
 	assert.Contains(t, err.Error(), "timed out waiting for go routines to complete")
 }
 
+func TestWaitContext(t *testing.T) {
+	errCh := make(chan error, 4)
+
+	var wg sync.WaitGroup
+	for j := range []int{0, 1, 2, 3} {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			time.Sleep(10 * time.Millisecond)
+		}(j)
+	}
+	assert.NoError(t, WaitContextOrError(context.Background(), &wg, errCh))
+
+	// error sent before completion
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errCh <- errors.New("fortio failed")
+	}()
+	assert.Error(t, WaitContextOrError(context.Background(), &wg, errCh))
+
+	// context deadline exceeded
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		time.Sleep(time.Second)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, WaitContextOrError(ctx, &wg, errCh))
+}
+
+func TestSleep(t *testing.T) {
+	assert.NoError(t, Sleep(context.Background(), time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, Sleep(ctx, time.Hour))
+}
+
 func TestSetLogLevel(t *testing.T) {
 	SetLogLevel(logrus.InfoLevel)
 	assert.Equal(t, logrus.InfoLevel, log.GetLevel())
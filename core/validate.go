@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/antonmedv/expr"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Validator is implemented by tasks that can check their inputs against an experiment before they are run.
+// Validate returns an error for each problem it finds, aggregated.
+type Validator interface {
+	Validate(exp *Experiment) error
+}
+
+// ValidateTask checks a task against an experiment without running it.
+// The 'if' condition of the task, if any, is compiled; and if the task implements Validator, its inputs are validated.
+// The returned aggregate holds all problems found, and is nil if there are none.
+func ValidateTask(exp *Experiment, t Task) utilerrors.Aggregate {
+	var errs []error
+	if cond := t.GetIf(); cond != nil {
+		env := getConditionEnv(ContextWithOutputs(context.Background()), exp)
+		if _, err := expr.Compile(*cond, expr.Env(env), expr.AsBool()); err != nil {
+			errs = append(errs, fmt.Errorf("invalid condition: %w", err))
+		}
+	}
+	if v, ok := t.(Validator); ok {
+		if err := v.Validate(exp); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.Flatten(utilerrors.NewAggregate(errs))
+}
+
+// ValidateTemplate checks that str can be parsed as a template by Tags.Interpolate.
+func ValidateTemplate(str string) error {
+	if _, err := (*Tags)(nil).parseTemplate(str, "", ""); err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	return nil
+}
+
+// ValidateVersionInfo checks that versionInfo of a task has an entry for every version of the experiment, in the same order.
+// An empty versionInfo is valid. Experiments without versions are not checked, since their versions may be added by an earlier task.
+func ValidateVersionInfo(exp *Experiment, versions []VersionInfo) error {
+	if len(versions) == 0 || exp.Spec.VersionInfo == nil {
+		return nil
+	}
+	if numVersions := 1 + len(exp.Spec.VersionInfo.Candidates); len(versions) != numVersions {
+		return fmt.Errorf("versionInfo has %d entries; experiment has %d versions", len(versions), numVersions)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type validateTestTask struct {
+	TaskMeta
+	err error
+}
+
+func (t *validateTestTask) Run(ctx context.Context) error {
+	return nil
+}
+
+func (t *validateTestTask) Validate(exp *Experiment) error {
+	return t.err
+}
+
+func TestValidateTask(t *testing.T) {
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/experiment10.yaml")).Build()
+	assert.NoError(t, err)
+
+	assert.Nil(t, ValidateTask(exp, &testTask{}))
+	assert.Nil(t, ValidateTask(exp, &testTask{TaskMeta: TaskMeta{If: StringPointer("WinnerFound() && tasks.a.outputs.b == 'c'")}}))
+
+	agg := ValidateTask(exp, &validateTestTask{
+		TaskMeta: TaskMeta{If: StringPointer("AnotherOneBytesTheDust()")},
+		err:      errors.New("bad inputs"),
+	})
+	assert.Len(t, agg.Errors(), 2)
+	assert.Contains(t, agg.Errors()[0].Error(), "invalid condition")
+	assert.Equal(t, "bad inputs", agg.Errors()[1].Error())
+}
+
+func TestValidateTemplate(t *testing.T) {
+	assert.NoError(t, ValidateTemplate("hello {{ .name }}"))
+	assert.Error(t, ValidateTemplate("hello {{ .name "))
+	// forms of run specs are valid
+	assert.NoError(t, ValidateTemplate(`echo {{ .Secret "token" }} {{ if .WinnerFound }}{{ .Name }}{{ end }}`))
+	assert.Error(t, ValidateTemplate("{{ omg }}"))
+}
+
+func TestValidateVersionInfo(t *testing.T) {
+	exp, err := (&Builder{}).FromFile(CompletePath("../", "testdata/experiment10.yaml")).Build()
+	assert.NoError(t, err)
+	numVersions := 1 + len(exp.Spec.VersionInfo.Candidates)
+
+	assert.NoError(t, ValidateVersionInfo(exp, nil))
+	assert.NoError(t, ValidateVersionInfo(exp, make([]VersionInfo, numVersions)))
+	assert.Error(t, ValidateVersionInfo(exp, make([]VersionInfo, numVersions+1)))
+
+	exp.Spec.VersionInfo = nil
+	assert.NoError(t, ValidateVersionInfo(exp, make([]VersionInfo, 3)))
+}
@@ -1,21 +1,25 @@
 package bash
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 
 	"github.com/iter8-tools/etc3/api/v2alpha2"
 	"github.com/iter8-tools/handler/core"
 	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
 var log *logrus.Logger
 
 func init() {
 	log = core.GetLogger()
+	core.MustRegisterTask(TaskName, Make)
 }
 
 const (
@@ -27,6 +31,7 @@ const (
 type Inputs struct {
 	VersionInfo []core.VersionInfo `json:"versionInfo,omitempty" yaml:"versionInfo,omitempty"`
 	Script      string             `json:"script" yaml:"script"`
+	Sources     []core.TagSource   `json:"sources,omitempty" yaml:"sources,omitempty"`
 }
 
 // Task encapsulates a command that can be executed.
@@ -53,41 +58,65 @@ func Make(t *v2alpha2.TaskSpec) (core.Task, error) {
 	return &task, err
 }
 
-// Run the command.
-func (t *Task) Run(ctx context.Context) error {
-	exp, err := core.GetExperimentFromContext(ctx)
+// Validate checks that the script is a valid template, that versionInfo matches the versions of the experiment, and that sources are valid.
+func (t *Task) Validate(exp *core.Experiment) error {
+	return utilerrors.NewAggregate([]error{
+		core.ValidateTemplate(t.With.Script),
+		core.ValidateVersionInfo(exp, t.With.VersionInfo),
+		core.ValidateTagSources(t.With.Sources),
+	})
+}
+
+// getScript returns the script with placeholders replaced by values.
+func (t *Task) getScript(ctx context.Context) (string, error) {
+	// Note that if versionRecommendedForPromotion is not set or there is no version corresponding to it,
+	// then some placeholders may not be replaced
+	tags, err := core.GetTemplateTags(ctx, t.With.VersionInfo)
 	if err != nil {
 		log.Error(err)
-		return err
+		return "", err
+	}
+	if *tags, err = tags.WithSources(ctx, t.With.Sources); err != nil {
+		return "", err
 	}
-	log.Trace("experiment", exp)
 
-	obj, err := exp.ToMap()
+	// interpolate - replaces placeholders in the script with values
+	return tags.Interpolate(&t.With.Script)
+}
+
+// Plan writes the interpolated script to w, without running it.
+func (t *Task) Plan(ctx context.Context, w io.Writer) error {
+	script, err := t.getScript(ctx)
 	if err != nil {
-		// error already logged by ToMap()
-		// don't log it again
 		return err
 	}
+	fmt.Fprintf(w, "command: /bin/bash -c\nscript:\n%s\n", script)
+	return nil
+}
 
-	// prepare for interpolation; add experiment as tag
-	// Note that if versionRecommendedForPromotion is not set or there is no version corresponding to it,
-	// then some placeholders may not be replaced
-	tags := core.NewTags().
-		With("this", obj).
-		WithRecommendedVersionForPromotion(&exp.Experiment, t.With.VersionInfo)
-
-	// interpolate - replaces placeholders in the script with values
-	script, _ := tags.Interpolate(&t.With.Script)
+// Run the command.
+func (t *Task) Run(ctx context.Context) error {
+	script, err := t.getScript(ctx)
+	if err != nil {
+		return err
+	}
 
 	log.Trace(script)
 	args := []string{"-c", script}
 
 	cmd := exec.Command("/bin/bash", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	// capture stdout so that it can be published as an output
+	var stdout bytes.Buffer
+	// sensitive values, such as the data of secrets, are masked in what the command prints
+	printedOut, printedErr := core.RedactingWriter(os.Stdout), core.RedactingWriter(os.Stderr)
+	cmd.Stdout = io.MultiWriter(printedOut, &stdout)
+	cmd.Stderr = printedErr
 	log.Info("Running task: " + cmd.String())
 	log.Trace(args)
-	err = cmd.Run()
+	err = core.RunCommand(ctx, cmd)
+	printedOut.Close()
+	printedErr.Close()
+	core.SetOutput(ctx, "stdout", stdout.String())
 
 	return err
 }
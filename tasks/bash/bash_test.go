@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -54,3 +56,68 @@ func TestBashRun(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, strings.Contains(buf.String(), "\necho \"v1\"\n"))
 }
+
+func TestBashOutput(t *testing.T) {
+	script, _ := json.Marshal("echo hello")
+	id, _ := json.Marshal("greet")
+	task, err := core.MakeTask(&v2alpha2.TaskSpec{
+		Task: core.StringPointer(TaskName),
+		With: map[string]apiextensionsv1.JSON{
+			"script": {Raw: script},
+			"id":     {Raw: id},
+		},
+	})
+	assert.NoError(t, err)
+
+	exp, err := (&core.Builder{}).FromFile(core.CompletePath("../../testdata/common", "bashexperiment.yaml")).Build()
+	assert.NoError(t, err)
+	ctx := core.ContextWithOutputs(context.WithValue(context.Background(), core.ContextKey("experiment"), exp))
+
+	assert.NoError(t, core.RunTask(ctx, task))
+	assert.Equal(t, "hello\n", core.GetOutputs(ctx)["greet"].(map[string]interface{})["outputs"].(map[string]interface{})["stdout"])
+}
+
+func TestBashPlan(t *testing.T) {
+	script, _ := json.Marshal("echo {{ .this.metadata.name }} > /tmp/bash-plan")
+	task, err := Make(&v2alpha2.TaskSpec{
+		Task: core.StringPointer(TaskName),
+		With: map[string]apiextensionsv1.JSON{
+			"script": {Raw: script},
+		},
+	})
+	assert.NoError(t, err)
+
+	exp, err := (&core.Builder{}).FromFile(core.CompletePath("../../testdata/common", "bashexperiment.yaml")).Build()
+	assert.NoError(t, err)
+	ctx := context.WithValue(context.Background(), core.ContextKey("experiment"), exp)
+
+	var buf bytes.Buffer
+	assert.NoError(t, task.(core.Planner).Plan(ctx, &buf))
+	assert.Contains(t, buf.String(), "echo quickstart-exp > /tmp/bash-plan")
+	_, err = os.Stat("/tmp/bash-plan")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestBashSources(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "endpoints.json")
+	assert.NoError(t, ioutil.WriteFile(file, []byte(`{"promote": "https://example.com/promote"}`), 0644))
+	script, _ := json.Marshal("curl {{ .endpoints.promote }}")
+	sources, _ := json.Marshal([]core.TagSource{{Name: "endpoints", File: core.StringPointer(file)}})
+	task, err := Make(&v2alpha2.TaskSpec{
+		Task: core.StringPointer(TaskName),
+		With: map[string]apiextensionsv1.JSON{
+			"script":  {Raw: script},
+			"sources": {Raw: sources},
+		},
+	})
+	assert.NoError(t, err)
+
+	exp, err := (&core.Builder{}).FromFile(core.CompletePath("../../testdata/common", "bashexperiment.yaml")).Build()
+	assert.NoError(t, err)
+	assert.NoError(t, task.(*Task).Validate(exp))
+	ctx := context.WithValue(context.Background(), core.ContextKey("experiment"), exp)
+
+	var buf bytes.Buffer
+	assert.NoError(t, task.(core.Planner).Plan(ctx, &buf))
+	assert.Contains(t, buf.String(), "curl https://example.com/promote")
+}
@@ -6,10 +6,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math"
 	"os"
 	"os/exec"
+	"strconv"
 	"sync"
 	"time"
 
@@ -17,6 +19,7 @@ import (
 	"github.com/iter8-tools/handler/core"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
 const (
@@ -34,6 +37,7 @@ var log *logrus.Logger
 
 func init() {
 	log = core.GetLogger()
+	core.MustRegisterTask(TaskName, Make)
 }
 
 // Version contains header and url information needed to send requests to each version.
@@ -163,6 +167,39 @@ func aggregate(oldResults map[string]*Result, version string, newResult *Result)
 	return oldResults
 }
 
+// VersionSummary summarizes the aggregated Fortio results for a version.
+type VersionSummary struct {
+	// Count is the number of requests
+	Count int `json:"count" yaml:"count"`
+	// ErrorCount is the number of requests that failed or returned a status code that is not 2xx or 3xx
+	ErrorCount int `json:"errorCount" yaml:"errorCount"`
+	// MeanLatency is the mean latency of requests in seconds
+	MeanLatency float64 `json:"meanLatency" yaml:"meanLatency"`
+	// MaxLatency is the maximum latency of requests in seconds
+	MaxLatency float64 `json:"maxLatency" yaml:"maxLatency"`
+}
+
+// summarize aggregated results by version
+func summarize(results map[string]*Result) map[string]VersionSummary {
+	summary := make(map[string]VersionSummary, len(results))
+	for version, r := range results {
+		s := VersionSummary{
+			Count:      r.DurationHistogram.Count,
+			MaxLatency: r.DurationHistogram.Max,
+		}
+		if s.Count > 0 {
+			s.MeanLatency = r.DurationHistogram.Sum / float64(s.Count)
+		}
+		for code, count := range r.RetCodes {
+			if c, err := strconv.Atoi(code); err != nil || c < 200 || c >= 400 {
+				s.ErrorCount += count
+			}
+		}
+		summary[version] = s
+	}
+	return summary
+}
+
 // getResultFromFile reads the contents from a Fortio output file and returns it as a Result
 func getResultFromFile(fortioOutputFile string) (*Result, error) {
 	// open JSON file
@@ -221,13 +258,33 @@ func payloadFile(url string) (string, error) {
 	return tmpfile.Name(), nil
 }
 
-// resultForVersion collects Fortio result for a given version
-func (t *CollectTask) resultForVersion(entry *logrus.Entry, j int, pf string) (*Result, error) {
-	// the main idea is to run Fortio shell command with proper args
-	// collect Fortio output as a file
-	// and extract the result from the file, and return the result
+// Validate checks that the time is a valid duration, and that every version has a URL and matches a version of the experiment, if any.
+func (t *CollectTask) Validate(exp *core.Experiment) error {
+	var errs []error
+	if t.With.Time != nil {
+		if _, err := time.ParseDuration(*t.With.Time); err != nil {
+			errs = append(errs, fmt.Errorf("time: %w", err))
+		}
+	}
+	if len(t.With.Versions) == 0 {
+		errs = append(errs, errors.New("collect task with no versions"))
+	}
+	for j, v := range t.With.Versions {
+		if len(v.URL) == 0 {
+			errs = append(errs, fmt.Errorf("version %d has no url", j))
+		}
+		// experiments without versions are not checked, since their versions may be added by an earlier task
+		if _, err := exp.GetVersionDetail(v.Name); err != nil && exp.Spec.VersionInfo != nil {
+			errs = append(errs, fmt.Errorf("version %d: %s is not a version of the experiment", j, v.Name))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
 
-	var execOut bytes.Buffer
+// fortioArgs returns the arguments of the Fortio command for a given version.
+// pf is the payload file, and jsonOutputFile is the file to which Fortio writes its result.
+// The trace context of ctx, if any, is propagated in the headers of the requests sent by Fortio.
+func (t *CollectTask) fortioArgs(ctx context.Context, j int, pf string, jsonOutputFile string) []string {
 	// appending Fortio load subcommand
 	args := []string{"load"}
 	// append Fortio time flag
@@ -238,33 +295,68 @@ func (t *CollectTask) resultForVersion(entry *logrus.Entry, j int, pf string) (*
 	for header, value := range t.With.Versions[j].Headers {
 		args = append(args, "-H", fmt.Sprintf("%v: %v", header, value))
 	}
-	// download JSON payload if specified; and append Fortio ayload-file flag
+	for header, value := range core.TraceHeaders(ctx) {
+		args = append(args, "-H", fmt.Sprintf("%v: %v", header, value))
+	}
+	// append Fortio payload-file flag if payload is specified
 	if t.With.PayloadURL != nil {
 		args = append(args, "-payload-file", pf)
 	}
+	// append Fortio json flag
+	args = append(args, "-json", jsonOutputFile)
+	// append URL to be queried by Fortio
+	args = append(args, t.With.Versions[j].URL)
+	return args
+}
+
+// Plan writes the Fortio command line for each version to w, without running Fortio.
+// The payload is not downloaded; its file is shown as a placeholder.
+func (t *CollectTask) Plan(ctx context.Context, w io.Writer) error {
+	t.InitializeDefaults()
+	if _, err := time.ParseDuration(*t.With.Time); err != nil {
+		return err
+	}
+	if t.With.PayloadURL != nil {
+		fmt.Fprintln(w, "payload:", *t.With.PayloadURL)
+	}
+	for j := range t.With.Versions {
+		args := t.fortioArgs(ctx, j, "<payload-file>", "<output-file>")
+		fmt.Fprintln(w, "version:", t.With.Versions[j].Name)
+		fmt.Fprintln(w, "command:", exec.Command("fortio", args...).String())
+	}
+	if t.With.LoadOnly == nil || !*t.With.LoadOnly {
+		fmt.Fprintln(w, "experiment status would be updated with results")
+	}
+	return nil
+}
+
+// resultForVersion collects Fortio result for a given version
+func (t *CollectTask) resultForVersion(ctx context.Context, entry *logrus.Entry, j int, pf string) (*Result, error) {
+	// the main idea is to run Fortio shell command with proper args
+	// collect Fortio output as a file
+	// and extract the result from the file, and return the result
+
+	var execOut bytes.Buffer
 
-	// create json output file; and Fortio append json flag
+	// create json output file
 	jsonOutputFile, err := ioutil.TempFile("/tmp", "output.json.")
 	if err != nil {
 		entry.Fatal(err)
 		return nil, err
 	}
-	args = append(args, "-json", jsonOutputFile.Name())
 	jsonOutputFile.Close()
 
-	// append URL to be queried by Fortio
-	args = append(args, t.With.Versions[j].URL)
-
 	// setup Fortio command
+	args := t.fortioArgs(ctx, j, pf, jsonOutputFile.Name())
 	cmd := exec.Command("fortio", args...)
 	cmd.Stdout = &execOut
 	cmd.Stderr = os.Stderr
 	entry.Trace("Invoking: " + cmd.String())
 
-	// execute Fortio command
-	err = cmd.Run()
+	// execute Fortio command; Fortio is killed if ctx is done before it completes
+	err = core.RunCommand(ctx, cmd)
 	if err != nil {
-		entry.Fatal(err)
+		entry.Error(err)
 		return nil, err
 	}
 
@@ -309,12 +401,21 @@ func (t *CollectTask) Run(ctx context.Context) error {
 		}
 	}
 
+	// Compute timeout as duration of fortio requests + 30s
+	// this is applied as a deadline on the context, in addition to any deadline the context already has
+	dur, err := time.ParseDuration(*t.With.Time)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, dur+30*time.Second)
+	defer cancel()
+
 	// lock ensures thread safety while updating fortioData from go routines
 	var lock sync.Mutex
 
 	// if errors occur in one of the parallel go routines, errCh is used to communicate them
-	errCh := make(chan error)
-	defer close(errCh)
+	// errCh is buffered so that go routines never block on it after this function has returned
+	errCh := make(chan error, len(t.With.Versions))
 
 	// download JSON from URL if specified
 	// this is intended to be used as a JSON payload file by Fortio
@@ -339,8 +440,11 @@ func (t *CollectTask) Run(ctx context.Context) error {
 			// Decrement the counter when the goroutine completes.
 			defer wg.Done()
 			// Get Fortio data for version
-			data, err := t.resultForVersion(entry, k, tmpfileName)
+			data, err := t.resultForVersion(ctx, entry, k, tmpfileName)
 			if err == nil {
+				for code, count := range data.RetCodes {
+					core.RecordCollectRequests(t.With.Versions[k].Name, code, count)
+				}
 				// if this task is **not** loadOnly
 				if t.With.LoadOnly == nil || !*t.With.LoadOnly {
 					// Update fortioData in a threadsafe manner
@@ -360,15 +464,10 @@ func (t *CollectTask) Run(ctx context.Context) error {
 	}
 
 	// See https://stackoverflow.com/questions/32840687/timeout-for-waitgroup-wait
-	// Compute timeout as duration of fortio requests + 30s
-	dur, err := time.ParseDuration(*t.With.Time)
-	if err != nil {
-		return err
-	}
 	// wait for WaitGroup to be done... normal execution
 	// timeout ... abnormal execution
 	// error on errCh ... abnormal execution
-	if err = core.WaitTimeoutOrError(&wg, dur+30*time.Second, errCh); err != nil {
+	if err = core.WaitContextOrError(ctx, &wg, errCh); err != nil {
 		log.Error("Got error: ", err)
 		return err
 	}
@@ -385,9 +484,15 @@ func (t *CollectTask) Run(ctx context.Context) error {
 			return err
 		}
 
-		exp.SetAggregatedBuiltinHists(v1.JSON{Raw: bytes1})
+		core.SetOutput(ctx, "summary", summarize(fortioData))
 
-		core.UpdateInClusterExperimentStatus(exp)
+		// this task owns only the aggregated builtin histograms in the status
+		if err = core.UpdateExperimentStatus(ctx, exp, func(e *core.Experiment) {
+			e.SetAggregatedBuiltinHists(v1.JSON{Raw: bytes1})
+		}); err != nil {
+			log.Error("could not update experiment status: ", err)
+			return err
+		}
 
 		var prettyBody bytes.Buffer
 		bytes2, _ := json.Marshal(exp)
@@ -1,10 +1,13 @@
 package collect
 
 import (
+	"bytes"
+	"context"
 	"testing"
 
 	"github.com/iter8-tools/handler/core"
 	"github.com/stretchr/testify/assert"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
 func TestInitializeCollectDefaults(t *testing.T) {
@@ -96,6 +99,18 @@ func TestGetResultFromFile(t *testing.T) {
 	assert.Nil(t, res)
 }
 
+func TestSummarize(t *testing.T) {
+	fileName := core.CompletePath("../../", "testdata/metricscollect/fortiooutput.json")
+	res, err := getResultFromFile(fileName)
+	assert.NoError(t, err)
+
+	res.RetCodes["503"] = 2
+	summary := summarize(map[string]*Result{"default": res})
+	assert.Equal(t, 40, summary["default"].Count)
+	assert.Equal(t, 2, summary["default"].ErrorCount)
+	assert.InDelta(t, 0.541547363/40, summary["default"].MeanLatency, 1e-9)
+}
+
 func TestPayloadFile(t *testing.T) {
 	url := "https://httpbin.org/stream/1"
 	fileName, err := payloadFile(url)
@@ -123,7 +138,48 @@ func TestResultForVersion(t *testing.T) {
 	}
 	ct.InitializeDefaults()
 	entry := log.WithField("version", "default")
-	res, err := ct.resultForVersion(entry, 0, "")
+	res, err := ct.resultForVersion(context.Background(), entry, 0, "")
 	assert.NoError(t, err)
 	assert.NotNil(t, res)
 }
+
+func TestCollectPlan(t *testing.T) {
+	ct := CollectTask{
+		TaskMeta: core.TaskMeta{
+			Task: core.StringPointer(TaskName),
+		},
+		With: CollectInputs{
+			Versions: []Version{{
+				Name:    "default",
+				URL:     "https://httpbin.org",
+				Headers: map[string]string{"x-foo": "bar"},
+			}},
+		},
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, ct.Plan(context.Background(), &buf))
+	assert.Contains(t, buf.String(), "version: default\n")
+	assert.Contains(t, buf.String(), "load -t 5s -qps 8.000000 -H x-foo: bar -json <output-file> https://httpbin.org\n")
+	assert.Contains(t, buf.String(), "experiment status would be updated with results\n")
+}
+
+func TestCollectValidate(t *testing.T) {
+	exp, err := (&core.Builder{}).FromFile(core.CompletePath("../../", "testdata/metricscollect/metricscollect.yaml")).Build()
+	assert.NoError(t, err)
+	ct := CollectTask{
+		With: CollectInputs{
+			Time: core.StringPointer("5 seconds"),
+			Versions: []Version{{
+				Name: "default",
+				URL:  "https://httpbin.org",
+			}, {
+				Name: "unknown",
+			}},
+		},
+	}
+	assert.Len(t, ct.Validate(exp).(utilerrors.Aggregate).Errors(), 3)
+
+	ct.With.Time = nil
+	ct.With.Versions = ct.With.Versions[:1]
+	assert.NoError(t, ct.Validate(exp))
+}
@@ -1,21 +1,25 @@
 package exec
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 
 	"github.com/iter8-tools/etc3/api/v2alpha2"
 	"github.com/iter8-tools/handler/core"
 	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
 var log *logrus.Logger
 
 func init() {
 	log = core.GetLogger()
+	core.MustRegisterTask(TaskName, Make)
 }
 
 const (
@@ -25,9 +29,10 @@ const (
 
 // Inputs contain the name and arguments of the command to be executed.
 type Inputs struct {
-	Cmd                  string        `json:"cmd" yaml:"cmd"`
-	Args                 []interface{} `json:"args,omitempty" yaml:"args,omitempty"`
-	DisableInterpolation bool          `json:"disableInterpolation,omitempty" yaml:"disableInterpolation,omitempty"`
+	Cmd                  string           `json:"cmd" yaml:"cmd"`
+	Args                 []interface{}    `json:"args,omitempty" yaml:"args,omitempty"`
+	DisableInterpolation bool             `json:"disableInterpolation,omitempty" yaml:"disableInterpolation,omitempty"`
+	Sources              []core.TagSource `json:"sources,omitempty" yaml:"sources,omitempty"`
 }
 
 // Task encapsulates a command that can be executed.
@@ -36,30 +41,77 @@ type Task struct {
 	With          Inputs `json:"with" yaml:"with"`
 }
 
+// Validate checks that the arguments are valid templates and that sources are valid, unless interpolation is disabled.
+func (t *Task) Validate(exp *core.Experiment) error {
+	if t.With.DisableInterpolation {
+		return nil
+	}
+	errs := []error{core.ValidateTagSources(t.With.Sources)}
+	for i := range t.With.Args {
+		if err := core.ValidateTemplate(fmt.Sprint(t.With.Args[i])); err != nil {
+			errs = append(errs, fmt.Errorf("arg %d: %w", i, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// getArgs returns the arguments of the command, interpolated unless interpolation is disabled.
+func (t *Task) getArgs(ctx context.Context) ([]string, error) {
+	inputArgs := make([]string, len(t.With.Args))
+	for i := 0; i < len(inputArgs); i++ {
+		inputArgs[i] = fmt.Sprint(t.With.Args[i])
+	}
+	log.Trace(inputArgs)
+	if t.With.DisableInterpolation {
+		return inputArgs, nil
+	}
+	tags, err := core.GetTemplateTags(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if *tags, err = tags.WithSources(ctx, t.With.Sources); err != nil {
+		return nil, err
+	}
+	args := make([]string, len(inputArgs))
+	for i := range inputArgs {
+		if args[i], err = tags.Interpolate(&inputArgs[i]); err != nil {
+			return nil, fmt.Errorf("arg %d: %w", i, err)
+		}
+	}
+	return args, nil
+}
+
+// Plan writes the command with interpolated arguments to w, without running it.
+func (t *Task) Plan(ctx context.Context, w io.Writer) error {
+	args, err := t.getArgs(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "command:", t.With.Cmd)
+	for _, arg := range args {
+		fmt.Fprintln(w, "arg:", arg)
+	}
+	return nil
+}
+
 // Run the command.
 func (t *Task) Run(ctx context.Context) error {
-	exp, err := core.GetExperimentFromContext(ctx)
+	args, err := t.getArgs(ctx)
 	if err == nil {
-		inputArgs := make([]string, len(t.With.Args))
-		for i := 0; i < len(inputArgs); i++ {
-			inputArgs[i] = fmt.Sprint(t.With.Args[i])
-		}
-		log.Trace(inputArgs)
-		var args []string
-		if t.With.DisableInterpolation {
-			args = inputArgs
-		} else {
-			args, err = exp.Interpolate(inputArgs)
-		}
-		if err == nil {
-			log.Trace("interpolated args: ", args)
-			cmd := exec.Command(t.With.Cmd, args...)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			log.Info("Running task: " + cmd.String())
-			log.Trace(args)
-			err = cmd.Run()
-		}
+		log.Trace("interpolated args: ", args)
+		cmd := exec.Command(t.With.Cmd, args...)
+		// capture stdout so that it can be published as an output
+		var stdout bytes.Buffer
+		// sensitive values, such as the data of secrets, are masked in what the command prints
+		printedOut, printedErr := core.RedactingWriter(os.Stdout), core.RedactingWriter(os.Stderr)
+		cmd.Stdout = io.MultiWriter(printedOut, &stdout)
+		cmd.Stderr = printedErr
+		log.Info("Running task: " + cmd.String())
+		log.Trace(args)
+		err = core.RunCommand(ctx, cmd)
+		printedOut.Close()
+		printedErr.Close()
+		core.SetOutput(ctx, "stdout", stdout.String())
 	}
 	if err != nil {
 		log.Error(err)
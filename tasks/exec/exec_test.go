@@ -3,7 +3,10 @@ package exec
 import (
 	"context"
 	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/iter8-tools/etc3/api/v2alpha2"
 	"github.com/iter8-tools/handler/core"
@@ -60,3 +63,64 @@ func TestExecTaskNoInterpolation(t *testing.T) {
 	exp, _ := (&core.Builder{}).FromFile(core.CompletePath("../../", "testdata/experiment10.yaml")).Build()
 	task.Run(context.WithValue(context.Background(), core.ContextKey("experiment"), exp))
 }
+
+func TestExecTaskTimeout(t *testing.T) {
+	b, _ := json.Marshal("sleep")
+	a, _ := json.Marshal([]string{"10"})
+	task, err := Make(&v2alpha2.TaskSpec{
+		Task: core.StringPointer("common/exec"),
+		With: map[string]apiextensionsv1.JSON{
+			"cmd":  {Raw: b},
+			"args": {Raw: a},
+		},
+	})
+	assert.NoError(t, err)
+
+	exp, _ := (&core.Builder{}).FromFile(core.CompletePath("../../", "testdata/experiment10.yaml")).Build()
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), core.ContextKey("experiment"), exp), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Error(t, task.Run(ctx))
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+}
+
+func TestExecTaskInterpolation(t *testing.T) {
+	b, _ := json.Marshal("echo")
+	a, _ := json.Marshal([]string{"{{ .this.metadata.name }}", `{"revision": "{{ .revision }}"}`})
+	task, err := Make(&v2alpha2.TaskSpec{
+		Task: core.StringPointer("common/exec"),
+		With: map[string]apiextensionsv1.JSON{
+			"cmd":  {Raw: b},
+			"args": {Raw: a},
+		},
+	})
+	assert.NoError(t, err)
+
+	exp, _ := (&core.Builder{}).FromFile(core.CompletePath("../../", "testdata/experiment6.yaml")).Build()
+	args, err := task.(*Task).getArgs(context.WithValue(context.Background(), core.ContextKey("experiment"), exp))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sklearn-iris-experiment-6", `{"revision": "revision1"}`}, args)
+}
+
+func TestExecTaskSources(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "channels.yaml")
+	assert.NoError(t, ioutil.WriteFile(file, []byte("canary: C123\n"), 0644))
+	b, _ := json.Marshal("echo")
+	a, _ := json.Marshal([]string{"{{ .slack.canary }}"})
+	s, _ := json.Marshal([]core.TagSource{{Name: "slack", File: core.StringPointer(file)}})
+	task, err := Make(&v2alpha2.TaskSpec{
+		Task: core.StringPointer("common/exec"),
+		With: map[string]apiextensionsv1.JSON{
+			"cmd":     {Raw: b},
+			"args":    {Raw: a},
+			"sources": {Raw: s},
+		},
+	})
+	assert.NoError(t, err)
+
+	exp, _ := (&core.Builder{}).FromFile(core.CompletePath("../../", "testdata/experiment6.yaml")).Build()
+	assert.NoError(t, task.(*Task).Validate(exp))
+	args, err := task.(*Task).getArgs(context.WithValue(context.Background(), core.ContextKey("experiment"), exp))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"C123"}, args)
+}
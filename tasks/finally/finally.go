@@ -0,0 +1,76 @@
+package finally
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/iter8-tools/etc3/api/v2alpha2"
+	"github.com/iter8-tools/handler/core"
+	// run specs in the group are made by runscript
+	_ "github.com/iter8-tools/handler/tasks/runscript"
+	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+const (
+	// TaskName is the name of the finally task group
+	TaskName string = "common/finally"
+)
+
+var log *logrus.Logger
+
+func init() {
+	log = core.GetLogger()
+	core.MustRegisterTaskGroup(TaskName, Make)
+}
+
+// Inputs contain the tasks in the group.
+type Inputs struct {
+	// Tasks is the list of task (or run) specs that are run in sequence
+	Tasks []v2alpha2.TaskSpec `json:"tasks" yaml:"tasks"`
+}
+
+// Task runs a group of tasks even if an earlier task in the action has failed.
+// This is useful for notifications and clean up that need to happen regardless of the outcome of the action.
+type Task struct {
+	core.TaskMeta `json:",inline" yaml:",inline"`
+	With          Inputs `json:"with" yaml:"with"`
+	// TaskGroup holds the tasks made from With.Tasks
+	core.TaskGroup `json:"-" yaml:"-"`
+}
+
+// Make converts a finally task spec into a finally task.
+func Make(t *v2alpha2.TaskSpec) (core.Task, error) {
+	var err error
+	task := Task{}
+	if task.TaskGroup, err = core.MakeTaskGroup(t, TaskName, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// IsFinally returns true; the group runs even if an earlier task in the action has failed.
+func (t *Task) IsFinally() bool {
+	return true
+}
+
+// Plan writes the plans of the tasks in the group to w, without running them.
+func (t *Task) Plan(ctx context.Context, w io.Writer) error {
+	fmt.Fprintf(w, "finally group of %d tasks; runs even if an earlier task fails\n", len(t.Tasks()))
+	return t.PlanTasks(ctx, w)
+}
+
+// Run all the tasks in the group in sequence, regardless of failures, and return an aggregate of their errors.
+func (t *Task) Run(ctx context.Context) error {
+	tasks := t.Tasks()
+	errs := make([]error, len(tasks))
+	for i := range tasks {
+		log.Info("------ finally task starting: ", i)
+		if err := core.RunTask(ctx, tasks[i]); err != nil {
+			log.Error("finally task ", i, " failed: ", err)
+			errs[i] = fmt.Errorf("finally task %d: %w", i, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,66 @@
+package finally
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iter8-tools/etc3/api/v2alpha2"
+	"github.com/iter8-tools/handler/core"
+	"github.com/iter8-tools/handler/tasks/runscript"
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func TestMakeTask(t *testing.T) {
+	task, err := Make(&v2alpha2.TaskSpec{
+		Task: core.StringPointer(TaskName),
+		With: map[string]apiextensionsv1.JSON{
+			"tasks": {Raw: []byte(`[{"run": "echo hello"}, {"run": "echo world"}]`)},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, task.(*Task).Tasks(), 2)
+	assert.True(t, task.(*Task).IsFinally())
+
+	_, err = Make(&v2alpha2.TaskSpec{
+		Task: core.StringPointer("fake/fake"),
+	})
+	assert.Error(t, err)
+
+	// no tasks
+	_, err = Make(&v2alpha2.TaskSpec{
+		Task: core.StringPointer(TaskName),
+	})
+	assert.Error(t, err)
+
+	// unknown task in group
+	_, err = Make(&v2alpha2.TaskSpec{
+		Task: core.StringPointer(TaskName),
+		With: map[string]apiextensionsv1.JSON{
+			"tasks": {Raw: []byte(`[{"task": "fake/fake"}]`)},
+		},
+	})
+	assert.Error(t, err)
+}
+
+func TestRunAfterFailure(t *testing.T) {
+	exp, err := (&core.Builder{}).FromFile(core.CompletePath("../../", "testdata/experiment10.yaml")).Build()
+	assert.NoError(t, err)
+	ctx := context.WithValue(context.Background(), core.ContextKey("experiment"), exp)
+
+	failing, err := runscript.Make(&v2alpha2.TaskSpec{Run: core.StringPointer("exit 1")})
+	assert.NoError(t, err)
+	finally, err := Make(&v2alpha2.TaskSpec{
+		Task: core.StringPointer(TaskName),
+		With: map[string]apiextensionsv1.JSON{
+			"tasks": {Raw: []byte(`[{"run": "exit 2"}, {"run": "echo cleaning up"}]`)},
+		},
+	})
+	assert.NoError(t, err)
+
+	action := core.Action{failing, finally}
+	err = action.Run(ctx)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "finally task 0")
+	assert.NotContains(t, err.Error(), "finally task 1")
+}
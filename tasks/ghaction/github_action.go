@@ -4,17 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 
 	"github.com/iter8-tools/etc3/api/v2alpha2"
 	"github.com/iter8-tools/handler/core"
 	"github.com/iter8-tools/handler/tasks/http"
 	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
 var log *logrus.Logger
 
 func init() {
 	log = core.GetLogger()
+	core.MustRegisterTask(TaskName, Make)
 }
 
 const (
@@ -32,6 +35,7 @@ type Inputs struct {
 	Ref           *string               `json:"ref,omitempty" yaml:"ref,omitempty"`
 	WFInputs      []v2alpha2.NamedValue `json:"inputs,omitempty" yaml:"inputs,omitempty"`
 	VersionInfo   []core.VersionInfo    `json:"versionInfo,omitempty" yaml:"versionInfo,omitempty"`
+	Sources       []core.TagSource      `json:"sources,omitempty" yaml:"sources,omitempty"`
 	IgnoreFailure *bool                 `json:"ignoreFailure,omitempty" yaml:"ignoreFailure,omitempty"`
 }
 
@@ -98,6 +102,8 @@ func (t *Task) ToHTTPTask() *http.Task {
 				Value: "application/vnd.github.v3+json",
 			}},
 			Body:          &body,
+			VersionInfo:   t.With.VersionInfo,
+			Sources:       t.With.Sources,
 			IgnoreFailure: t.With.IgnoreFailure,
 		},
 	}
@@ -112,6 +118,19 @@ func (t *Task) ToHTTPTask() *http.Task {
 	return tSpec
 }
 
+// Validate checks the workflow dispatch request, and that versionInfo matches the versions of the experiment.
+func (t *Task) Validate(exp *core.Experiment) error {
+	return utilerrors.NewAggregate([]error{
+		t.ToHTTPTask().Validate(exp),
+		core.ValidateVersionInfo(exp, t.With.VersionInfo),
+	})
+}
+
+// Plan writes the workflow dispatch request to w, without sending it.
+func (t *Task) Plan(ctx context.Context, w io.Writer) error {
+	return t.ToHTTPTask().Plan(ctx, w)
+}
+
 // Run the task. Ignores failures unless the task indicates ignoreFailures: false
 func (t *Task) Run(ctx context.Context) error {
 	return t.ToHTTPTask().Run(ctx)
@@ -7,24 +7,32 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/iter8-tools/etc3/api/v2alpha2"
 	"github.com/iter8-tools/handler/core"
 	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
 const (
 	// TaskName is the name of the HTTP request task
 	TaskName string = "notification/http"
+
+	// DefaultTimeout is the timeout of the HTTP request when the context has no deadline
+	DefaultTimeout = 5 * time.Second
 )
 
 var log *logrus.Logger
 
 func init() {
 	log = core.GetLogger()
+	core.MustRegisterTask(TaskName, Make)
 }
 
 // Inputs contain the name and arguments of the task.
@@ -36,6 +44,7 @@ type Inputs struct {
 	Headers       []v2alpha2.NamedValue `json:"headers,omitempty" yaml:"headers,omitempty"`
 	Body          *string               `json:"body,omitempty" yaml:"body,omitempty"`
 	VersionInfo   []core.VersionInfo    `json:"versionInfo,omitempty" yaml:"versionInfo,omitempty"`
+	Sources       []core.TagSource      `json:"sources,omitempty" yaml:"sources,omitempty"`
 	IgnoreFailure *bool                 `json:"ignoreFailure,omitempty" yaml:"ignoreFailure,omitempty"`
 }
 
@@ -63,36 +72,36 @@ func Make(t *v2alpha2.TaskSpec) (core.Task, error) {
 	return &task, err
 }
 
-func (t *Task) prepareRequest(ctx context.Context) (*http.Request, error) {
-	tags := core.NewTags()
+// prepareRequest interpolates the request of the task.
+// If masked, the values of the secret are interpolated as core.RedactedValue, so that the request can be shown without revealing them.
+func (t *Task) prepareRequest(ctx context.Context, masked bool) (*http.Request, error) {
 	exp, err := core.GetExperimentFromContext(ctx)
 	if err != nil {
 		log.Error(err)
 		return nil, err
 	}
 
-	obj, err := exp.ToMap()
+	// prepare for interpolation
+	// Note that if versionRecommendedForPromotion is not set or there is no version corresponding to it,
+	// then some placeholders may not be replaced
+	tags, err := core.GetTemplateTags(ctx, t.With.VersionInfo)
 	if err != nil {
-		// error already logged by ToMap()
-		// don't log it again
 		return nil, err
 	}
-
-	// prepare for interpolation; add experiment as tag
-	// Note that if versionRecommendedForPromotion is not set or there is no version corresponding to it,
-	// then some placeholders may not be replaced
-	tags = tags.
-		With("this", obj).
-		WithRecommendedVersionForPromotion(&exp.Experiment, t.With.VersionInfo)
+	if *tags, err = tags.WithSources(ctx, t.With.Sources); err != nil {
+		return nil, err
+	}
 
 	// log tags now before secret is added; we don't log the secret
 	log.Trace("tags without secrets: ", tags)
 
 	secretName := t.With.Secret
 	if secretName != nil {
-		secret, err := core.GetSecret(*secretName)
-		if err == nil {
-			tags = tags.WithSecret("secret", secret)
+		secret, err := core.GetSecret(ctx, *secretName)
+		if err == nil && masked {
+			*tags = tags.WithMaskedSecret("secret", secret)
+		} else if err == nil {
+			*tags = tags.WithSecret("secret", secret)
 		}
 	}
 	log.Trace("tags with secrets: ", tags)
@@ -126,12 +135,14 @@ func (t *Task) prepareRequest(ctx context.Context) (*http.Request, error) {
 	}
 	log.Trace("authType: ", *authType)
 
-	req, err := http.NewRequest(string(*method), t.With.URL, strings.NewReader(*body))
+	req, err := http.NewRequestWithContext(ctx, string(*method), t.With.URL, strings.NewReader(*body))
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-type", "application/json")
+	// propagate the trace context of the task, if any
+	core.InjectTraceHeaders(ctx, req.Header)
 	for _, h := range t.With.Headers {
 		hValue, err := tags.Interpolate(&h.Value)
 		if err != nil {
@@ -146,7 +157,10 @@ func (t *Task) prepareRequest(ctx context.Context) (*http.Request, error) {
 		passwordTemplate := "{{ .secret.password }}"
 		username, _ := tags.Interpolate(&usernameTemplate)
 		password, _ := tags.Interpolate(&passwordTemplate)
-		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+		credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+		// the encoded credentials are derived from the secret, and masked in logs as well
+		core.RegisterSensitive(credentials)
+		req.Header.Set("Authorization", "Basic "+credentials)
 	} else if *authType == v2alpha2.BearerAuthType {
 		tokenTemplate := "{{ .secret.token }}"
 		token, _ := tags.Interpolate(&tokenTemplate)
@@ -209,15 +223,16 @@ func defaultBody(experiment v2alpha2.Experiment) (string, error) {
 
 // Run the command.
 func (t *Task) internalRun(ctx context.Context) error {
-	req, err := t.prepareRequest(ctx)
+	req, err := t.prepareRequest(ctx, false)
 
 	if err != nil {
 		return err
 	}
 
-	// send request
-	var httpClient = &http.Client{
-		Timeout: time.Second * 5,
+	// send request; the request is bound to ctx, so its deadline, if any, applies
+	var httpClient = &http.Client{}
+	if _, ok := ctx.Deadline(); !ok {
+		httpClient.Timeout = DefaultTimeout
 	}
 
 	resp, err := httpClient.Do(req)
@@ -226,20 +241,82 @@ func (t *Task) internalRun(ctx context.Context) error {
 		return err
 	}
 
+	defer resp.Body.Close()
+
 	log.Info("RESPONSE STATUS: " + resp.Status)
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(resp.Body)
+	setOutputs(ctx, resp, buf.Bytes())
 	if resp.StatusCode >= 400 {
 
 		err = errors.New(resp.Status)
 		log.Error(err)
 		return err
 	}
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(resp.Body)
 	log.Info(buf.String())
 
 	return nil
 }
 
+// setOutputs publishes the status and body of the response as outputs.
+// If the body is JSON, it is also published as an object under the name json.
+func setOutputs(ctx context.Context, resp *http.Response, body []byte) {
+	core.SetOutput(ctx, "status", resp.Status)
+	core.SetOutput(ctx, "statusCode", resp.StatusCode)
+	core.SetOutput(ctx, "body", string(body))
+	var obj interface{}
+	if err := json.Unmarshal(body, &obj); err == nil {
+		core.SetOutput(ctx, "json", obj)
+	}
+}
+
+// Validate checks that the body and header values are valid templates, that versionInfo matches the versions of the experiment, and that sources are valid.
+func (t *Task) Validate(exp *core.Experiment) error {
+	var errs []error
+	if t.With.Body != nil {
+		if err := core.ValidateTemplate(*t.With.Body); err != nil {
+			errs = append(errs, fmt.Errorf("body: %w", err))
+		}
+	}
+	for _, h := range t.With.Headers {
+		if err := core.ValidateTemplate(h.Value); err != nil {
+			errs = append(errs, fmt.Errorf("header %s: %w", h.Name, err))
+		}
+	}
+	errs = append(errs, core.ValidateVersionInfo(exp, t.With.VersionInfo))
+	errs = append(errs, core.ValidateTagSources(t.With.Sources))
+	return utilerrors.NewAggregate(errs)
+}
+
+// Plan writes the interpolated request to w, without sending it.
+// Values of the secret are written as core.RedactedValue wherever they appear in the headers and body,
+// and the value of the Authorization header is not written, since it is derived from a secret.
+func (t *Task) Plan(ctx context.Context, w io.Writer) error {
+	req, err := t.prepareRequest(ctx, true)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "request:", req.Method, req.URL)
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := req.Header.Get(name)
+		if name == "Authorization" {
+			value = "<redacted>"
+		}
+		fmt.Fprintf(w, "header: %s: %s\n", name, value)
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "body:\n%s\n", body)
+	return nil
+}
+
 // Run the task. Ignores failures unless the task indicates ignoreFailures: false
 func (t *Task) Run(ctx context.Context) error {
 	err := t.internalRun(ctx)
@@ -55,7 +55,7 @@ var _ = Describe("notification/http task", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			By("preparing the task")
-			req, err := task.(*Task).prepareRequest(ctx)
+			req, err := task.(*Task).prepareRequest(ctx, false)
 			Expect(err).ToNot(HaveOccurred())
 
 			By("checking the Authorization header")
@@ -98,7 +98,7 @@ var _ = Describe("notification/http task", func() {
 			Expect(err).ToNot(HaveOccurred())
 
 			By("preparing the task")
-			req, err := task.(*Task).prepareRequest(ctx)
+			req, err := task.(*Task).prepareRequest(ctx, false)
 			Expect(err).ToNot(HaveOccurred())
 
 			By("checking the Authorization header")
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"io/ioutil"
@@ -9,7 +10,9 @@ import (
 	"github.com/iter8-tools/etc3/api/v2alpha2"
 	"github.com/iter8-tools/handler/core"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestMakeFakeNotificationTask(t *testing.T) {
@@ -50,7 +53,7 @@ func TestMakeHttpTask(t *testing.T) {
 	assert.NoError(t, err)
 	ctx := context.WithValue(context.Background(), core.ContextKey("experiment"), exp)
 
-	req, err := task.(*Task).prepareRequest(ctx)
+	req, err := task.(*Task).prepareRequest(ctx, false)
 	assert.NotEmpty(t, task)
 	assert.NoError(t, err)
 
@@ -76,7 +79,7 @@ func TestMakeHttpTaskDefaults(t *testing.T) {
 	assert.NoError(t, err)
 	ctx := context.WithValue(context.Background(), core.ContextKey("experiment"), exp)
 
-	req, err := task.(*Task).prepareRequest(ctx)
+	req, err := task.(*Task).prepareRequest(ctx, false)
 	assert.NotEmpty(t, task)
 	assert.NoError(t, err)
 
@@ -90,3 +93,70 @@ func TestMakeHttpTaskDefaults(t *testing.T) {
 	expectedBody := `{"summary":{"winnerFound":false,"versionRecommendedForPromotion":"default"},"experiment":{"kind":"Experiment","apiVersion":"iter8.tools/v2alpha2","metadata":{"name":"sklearn-iris-experiment-1","namespace":"default","selfLink":"/apis/iter8.tools/v2alpha2/namespaces/default/experiments/sklearn-iris-experiment-1","uid":"b99489b6-a1b4-420f-9615-165d6ff88293","generation":2,"creationTimestamp":"2020-12-27T21:55:48Z","annotations":{"kubectl.kubernetes.io/last-applied-configuration":"{\"apiVersion\":\"iter8.tools/v2alpha2\",\"kind\":\"Experiment\",\"metadata\":{\"annotations\":{},\"name\":\"sklearn-iris-experiment-1\",\"namespace\":\"default\"},\"spec\":{\"criteria\":{\"indicators\":[\"95th-percentile-tail-latency\"],\"objectives\":[{\"metric\":\"mean-latency\",\"upperLimit\":1000},{\"metric\":\"error-rate\",\"upperLimit\":\"0.01\"}]},\"duration\":{\"intervalSeconds\":15,\"iterationsPerLoop\":10},\"strategy\":{\"type\":\"Canary\"},\"target\":\"default/sklearn-iris\"}}\n"}},"spec":{"target":"default/sklearn-iris","versionInfo":{"baseline":{"name":"default","variables":[{"name":"revision","value":"revision1"}]},"candidates":[{"name":"canary","variables":[{"name":"revision","value":"revision2"}],"weightObjRef":{"kind":"InferenceService","namespace":"default","name":"sklearn-iris","apiVersion":"serving.kubeflow.org/v1alpha2","fieldPath":".spec.canaryTrafficPercent"}}]},"strategy":{"testingPattern":"Canary","deploymentPattern":"Progressive","actions":{"finish":[{"task":"common/exec","with":{"args":["build","."],"cmd":"kustomize"}}],"start":[{"task":"common/exec","with":{"args":["hello-world","hello {{ revision }} world","hello {{ omg }} world"],"cmd":"echo"}},{"task":"common/exec","with":{"args":["v1","v2",20,40.5],"cmd":"helm"}}]},"weights":{"maxCandidateWeight":100,"maxCandidateWeightIncrement":10}},"criteria":{"requestCount":"request-count","indicators":["95th-percentile-tail-latency"],"objectives":[{"metric":"mean-latency","upperLimit":"1k"},{"metric":"error-rate","upperLimit":"10m"}],"strength":null},"duration":{"intervalSeconds":15,"iterationsPerLoop":10}},"status":{"conditions":[{"type":"Completed","status":"False","lastTransitionTime":"2020-12-27T21:55:49Z","reason":"StartHandlerLaunched","message":"Start handler 'start' launched"},{"type":"Failed","status":"False","lastTransitionTime":"2020-12-27T21:55:48Z"}],"initTime":"2020-12-27T21:55:48Z","lastUpdateTime":"2020-12-27T21:55:48Z","completedIterations":0,"versionRecommendedForPromotion":"default","message":"StartHandlerLaunched: Start handler 'start' launched"}}}`
 	assert.Equal(t, expectedBody, string(data))
 }
+
+func TestHttpPlan(t *testing.T) {
+	url, _ := json.Marshal("http://postman-echo.com/post")
+	body, _ := json.Marshal("{\"name\":\"{{ .this.metadata.name }}\"}")
+	headers, _ := json.Marshal([]v2alpha2.NamedValue{{
+		Name:  "x-foo",
+		Value: "{{ .this.metadata.namespace }}",
+	}})
+	task, err := Make(&v2alpha2.TaskSpec{
+		Task: core.StringPointer(TaskName),
+		With: map[string]apiextensionsv1.JSON{
+			"URL":     {Raw: url},
+			"body":    {Raw: body},
+			"headers": {Raw: headers},
+		},
+	})
+	assert.NoError(t, err)
+	exp, err := (&core.Builder{}).FromFile(core.CompletePath("../../", "testdata/experiment1.yaml")).Build()
+	assert.NoError(t, err)
+	ctx := context.WithValue(context.Background(), core.ContextKey("experiment"), exp)
+
+	var buf bytes.Buffer
+	assert.NoError(t, task.(*Task).Plan(ctx, &buf))
+	assert.Contains(t, buf.String(), "request: POST http://postman-echo.com/post\n")
+	assert.Contains(t, buf.String(), "header: X-Foo: default\n")
+	assert.Contains(t, buf.String(), "body:\n{\"name\":\"sklearn-iris-experiment-1\"}\n")
+}
+
+func TestHttpPlanMasksSecrets(t *testing.T) {
+	c, err := core.NewFakeCluster()
+	assert.NoError(t, err)
+	core.SetClient(c)
+	defer core.SetClient(nil)
+	assert.NoError(t, c.Create(context.Background(), &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "webhook", Namespace: "default"},
+		// the key is too short to be masked by the redactor; it is masked since it comes from the secret
+		Data: map[string][]byte{"key": []byte("k3y"), "token": []byte("plan-body-token")},
+	}))
+
+	url, _ := json.Marshal("http://postman-echo.com/post")
+	body, _ := json.Marshal("{\"token\":\"{{ .secret.token }}\"}")
+	headers, _ := json.Marshal([]v2alpha2.NamedValue{{
+		Name:  "x-api-key",
+		Value: "{{ .secret.key }}",
+	}})
+	secret, _ := json.Marshal("default/webhook")
+	task, err := Make(&v2alpha2.TaskSpec{
+		Task: core.StringPointer(TaskName),
+		With: map[string]apiextensionsv1.JSON{
+			"URL":     {Raw: url},
+			"body":    {Raw: body},
+			"headers": {Raw: headers},
+			"secret":  {Raw: secret},
+		},
+	})
+	assert.NoError(t, err)
+	exp, err := (&core.Builder{}).FromFile(core.CompletePath("../../", "testdata/experiment1.yaml")).Build()
+	assert.NoError(t, err)
+	ctx := context.WithValue(context.Background(), core.ContextKey("experiment"), exp)
+
+	var buf bytes.Buffer
+	assert.NoError(t, task.(*Task).Plan(ctx, &buf))
+	assert.NotContains(t, buf.String(), "k3y")
+	assert.NotContains(t, buf.String(), "plan-body-token")
+	assert.Contains(t, buf.String(), "header: X-Api-Key: "+core.RedactedValue+"\n")
+	assert.Contains(t, buf.String(), "body:\n{\"token\":\""+core.RedactedValue+"\"}\n")
+}
@@ -0,0 +1,118 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/iter8-tools/etc3/api/v2alpha2"
+	"github.com/iter8-tools/handler/core"
+	// run specs in the group are made by runscript
+	_ "github.com/iter8-tools/handler/tasks/runscript"
+	"github.com/sirupsen/logrus"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+const (
+	// TaskName is the name of the parallel task group
+	TaskName string = "common/parallel"
+)
+
+var log *logrus.Logger
+
+func init() {
+	log = core.GetLogger()
+	core.MustRegisterTaskGroup(TaskName, Make)
+}
+
+// Inputs contain the tasks in the group and how they are to be run.
+type Inputs struct {
+	// Tasks is the list of task (or run) specs that are run in parallel
+	Tasks []v2alpha2.TaskSpec `json:"tasks" yaml:"tasks"`
+	// MaxConcurrency is optional and bounds the number of tasks running at any time. If left unspecified, all tasks in the group run concurrently.
+	MaxConcurrency *int32 `json:"maxConcurrency,omitempty" yaml:"maxConcurrency,omitempty"`
+	// FailFast is optional and defaulted to false.
+	// If true, the first failure cancels the context of the running tasks and prevents pending tasks from starting.
+	// If false, all tasks run to completion regardless of failures.
+	FailFast *bool `json:"failFast,omitempty" yaml:"failFast,omitempty"`
+}
+
+// Task runs a group of tasks in parallel.
+type Task struct {
+	core.TaskMeta `json:",inline" yaml:",inline"`
+	With          Inputs `json:"with" yaml:"with"`
+	// TaskGroup holds the tasks made from With.Tasks
+	core.TaskGroup `json:"-" yaml:"-"`
+}
+
+// Make converts a parallel task spec into a parallel task.
+func Make(t *v2alpha2.TaskSpec) (core.Task, error) {
+	var err error
+	task := Task{}
+	if task.TaskGroup, err = core.MakeTaskGroup(t, TaskName, &task); err != nil {
+		return nil, err
+	}
+	if task.With.MaxConcurrency != nil && *task.With.MaxConcurrency < 1 {
+		return nil, errors.New("maxConcurrency needs to be positive")
+	}
+	return &task, nil
+}
+
+// Plan writes the plans of the tasks in the group to w, without running them.
+func (t *Task) Plan(ctx context.Context, w io.Writer) error {
+	fmt.Fprintf(w, "parallel group of %d tasks\n", len(t.Tasks()))
+	return t.PlanTasks(ctx, w)
+}
+
+// Run the tasks in the group in parallel, and return an aggregate of their errors.
+func (t *Task) Run(ctx context.Context) error {
+	tasks := t.Tasks()
+	maxConcurrency := len(tasks)
+	if t.With.MaxConcurrency != nil && int(*t.With.MaxConcurrency) < maxConcurrency {
+		maxConcurrency = int(*t.With.MaxConcurrency)
+	}
+	failFast := t.With.FailFast != nil && *t.With.FailFast
+
+	groupCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// sem bounds the number of concurrently running tasks
+	sem := make(chan struct{}, maxConcurrency)
+	errs := make([]error, len(tasks))
+	var wg sync.WaitGroup
+
+	for i := range tasks {
+		// acquire a slot, unless the group has been cancelled while waiting
+		select {
+		case sem <- struct{}{}:
+		case <-groupCtx.Done():
+		}
+		if groupCtx.Err() != nil {
+			log.Warn("parallel group cancelled; not starting task ", i)
+			break
+		}
+
+		wg.Add(1)
+		go func(k int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			log.Info("------ parallel task starting: ", k)
+			if err := core.RunTask(groupCtx, tasks[k]); err != nil {
+				log.Error("parallel task ", k, " failed: ", err)
+				errs[k] = fmt.Errorf("task %d: %w", k, err)
+				if failFast {
+					cancel()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// report cancellation of the enclosing context, which may have prevented tasks from starting
+	if ctx.Err() != nil {
+		errs = append(errs, ctx.Err())
+	}
+	return utilerrors.NewAggregate(errs)
+}
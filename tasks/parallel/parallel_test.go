@@ -0,0 +1,103 @@
+package parallel
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/iter8-tools/etc3/api/v2alpha2"
+	"github.com/iter8-tools/handler/core"
+	"github.com/stretchr/testify/assert"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func makeParallelTask(t *testing.T, runs []string, with map[string]interface{}) (core.Task, error) {
+	specs := make([]v2alpha2.TaskSpec, len(runs))
+	for i := range runs {
+		specs[i] = v2alpha2.TaskSpec{Run: core.StringPointer(runs[i])}
+	}
+	w := map[string]apiextensionsv1.JSON{}
+	tasks, _ := json.Marshal(specs)
+	w["tasks"] = apiextensionsv1.JSON{Raw: tasks}
+	for k, v := range with {
+		b, _ := json.Marshal(v)
+		w[k] = apiextensionsv1.JSON{Raw: b}
+	}
+	return Make(&v2alpha2.TaskSpec{
+		Task: core.StringPointer(TaskName),
+		With: w,
+	})
+}
+
+func getContext(t *testing.T) context.Context {
+	exp, err := (&core.Builder{}).FromFile(core.CompletePath("../../", "testdata/experiment10.yaml")).Build()
+	assert.NoError(t, err)
+	return context.WithValue(context.Background(), core.ContextKey("experiment"), exp)
+}
+
+func TestMakeTask(t *testing.T) {
+	task, err := makeParallelTask(t, []string{"echo hello", "echo world"}, nil)
+	assert.NoError(t, err)
+	assert.Len(t, task.(*Task).Tasks(), 2)
+
+	_, err = Make(&v2alpha2.TaskSpec{
+		Task: core.StringPointer("fake/fake"),
+	})
+	assert.Error(t, err)
+
+	// no tasks
+	_, err = makeParallelTask(t, nil, nil)
+	assert.Error(t, err)
+
+	// bad concurrency
+	_, err = makeParallelTask(t, []string{"echo hello"}, map[string]interface{}{"maxConcurrency": 0})
+	assert.Error(t, err)
+
+	// unknown task in group
+	_, err = Make(&v2alpha2.TaskSpec{
+		Task: core.StringPointer(TaskName),
+		With: map[string]apiextensionsv1.JSON{
+			"tasks": {Raw: []byte(`[{"task": "fake/fake"}]`)},
+		},
+	})
+	assert.Error(t, err)
+}
+
+func TestRunConcurrently(t *testing.T) {
+	task, err := makeParallelTask(t, []string{"sleep 0.5", "sleep 0.5", "sleep 0.5"}, nil)
+	assert.NoError(t, err)
+	start := time.Now()
+	assert.NoError(t, task.Run(getContext(t)))
+	assert.Less(t, int64(time.Since(start)), int64(1200*time.Millisecond))
+}
+
+func TestRunBoundedConcurrency(t *testing.T) {
+	task, err := makeParallelTask(t, []string{"sleep 0.5", "sleep 0.5"}, map[string]interface{}{"maxConcurrency": 1})
+	assert.NoError(t, err)
+	start := time.Now()
+	assert.NoError(t, task.Run(getContext(t)))
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestRunWaitForAll(t *testing.T) {
+	task, err := makeParallelTask(t, []string{"exit 1", "exit 2", "echo hello"}, nil)
+	assert.NoError(t, err)
+	err = task.Run(getContext(t))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "task 0")
+	assert.Contains(t, err.Error(), "task 1")
+}
+
+func TestRunFailFast(t *testing.T) {
+	task, err := makeParallelTask(t, []string{"exit 1", "sleep 0.5", "exit 2"}, map[string]interface{}{
+		"maxConcurrency": 1,
+		"failFast":       true,
+	})
+	assert.NoError(t, err)
+	err = task.Run(getContext(t))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "task 0")
+	// remaining tasks are never started
+	assert.NotContains(t, err.Error(), "task 2")
+}
@@ -35,6 +35,7 @@ var log *logrus.Logger
 
 func init() {
 	log = core.GetLogger()
+	core.MustRegisterTask(TaskName, Make)
 }
 
 // regex object
@@ -136,6 +137,14 @@ var getCommand = func(name string, arg ...string) command {
 	return exec.Command(name, arg...)
 }
 
+// runCommand runs the command; if the command is a shell command, it is killed when ctx is done
+func runCommand(ctx context.Context, cmd command) error {
+	if c, ok := cmd.(*exec.Cmd); ok {
+		return core.RunCommand(ctx, c)
+	}
+	return cmd.Run()
+}
+
 // Run checks existence and readiness of K8s objects.
 func (t *ReadinessTask) Run(ctx context.Context) error {
 	exp, err := core.GetExperimentFromContext(ctx)
@@ -172,7 +181,13 @@ func (t *ReadinessTask) Run(ctx context.Context) error {
 
 	log.Info("The task...")
 	log.Info(t)
-	time.Sleep(time.Duration(*t.With.InitialDelaySeconds) * time.Second)
+	if err = core.Sleep(ctx, time.Duration(*t.With.InitialDelaySeconds)*time.Second); err != nil {
+		return err
+	}
+	// sensitive values, such as the data of secrets, are masked in what kubectl prints
+	printedOut, printedErr := core.RedactingWriter(os.Stdout), core.RedactingWriter(os.Stderr)
+	defer printedOut.Close()
+	defer printedErr.Close()
 	// invariant: objIndex is the number of objects that have been checked and found to be good
 	objIndex := 0
 	for i := 0; i <= int(*t.With.NumRetries); i++ {
@@ -192,12 +207,12 @@ func (t *ReadinessTask) Run(ctx context.Context) error {
 
 			_, ok := cmd.(*exec.Cmd)
 			if ok {
-				cmd.(*exec.Cmd).Stdout = os.Stdout
-				cmd.(*exec.Cmd).Stderr = os.Stderr
+				cmd.(*exec.Cmd).Stdout = printedOut
+				cmd.(*exec.Cmd).Stderr = printedErr
 			}
 
 			log.Info("Executing command: " + cmd.String())
-			err = cmd.Run()
+			err = runCommand(ctx, cmd)
 			if err == nil {
 				// check readiness condition if any
 				if t.With.ObjRefs[i].WaitFor != nil {
@@ -206,12 +221,12 @@ func (t *ReadinessTask) Run(ctx context.Context) error {
 
 					_, ok := cmd.(*exec.Cmd)
 					if ok {
-						cmd.(*exec.Cmd).Stdout = os.Stdout
-						cmd.(*exec.Cmd).Stderr = os.Stderr
+						cmd.(*exec.Cmd).Stdout = printedOut
+						cmd.(*exec.Cmd).Stderr = printedErr
 					}
 
 					log.Info("Executing command: " + cmd.String())
-					err = cmd.Run()
+					err = runCommand(ctx, cmd)
 				}
 			}
 
@@ -225,7 +240,9 @@ func (t *ReadinessTask) Run(ctx context.Context) error {
 			break // out of the for loop
 		} else {
 			// try again later
-			time.Sleep(time.Duration(*t.With.IntervalSeconds) * time.Second)
+			if ctxErr := core.Sleep(ctx, time.Duration(*t.With.IntervalSeconds)*time.Second); ctxErr != nil {
+				return ctxErr
+			}
 		}
 	}
 
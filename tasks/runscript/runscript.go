@@ -4,22 +4,21 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"text/template"
 
 	"github.com/iter8-tools/etc3/api/v2alpha2"
 	"github.com/iter8-tools/handler/core"
 	"github.com/sirupsen/logrus"
-	corev1 "k8s.io/api/core/v1"
 )
 
 var log *logrus.Logger
 
 func init() {
 	log = core.GetLogger()
+	core.MustRegisterRun(Make)
 }
 
 const (
@@ -53,65 +52,35 @@ func Make(t *v2alpha2.TaskSpec) (core.Task, error) {
 	// convert jsonString to ExecTask
 	task = Task{}
 	err = json.Unmarshal(jsonBytes, &task)
-	return &task, err
-}
-
-// EnhancedExperiment supports enhanced interpolation behaviors
-type EnhancedExperiment struct {
-	*core.Experiment
-	sec *corev1.Secret
-}
-
-// Secret returns a value (of type string) for a key.
-// This uses the secret embedded in the enhanced experiment.
-// If the key is absent, an error will be returned.
-// If the value is not a string, an error will be returned.
-func (ee *EnhancedExperiment) Secret(key string) (string, error) {
-	if ee.sec == nil {
-		return "", errors.New("no secret specified")
-	}
-	val, ok := ee.sec.Data[key]
-	if !ok {
-		return "", fmt.Errorf("specified key %s seems absent in secret", key)
+	if err != nil {
+		return nil, err
 	}
-	return string(val), nil
+	err = core.LoadTaskMeta(&task, t)
+	return &task, err
 }
 
 // Interpolate the script.
+// The script is interpolated with the tags of core.GetTemplateTags, in which the secret of the task, if any, is available as {{ .Secret "key" }}.
 func (t *Task) Interpolate(ctx context.Context) error {
-	exp, err := core.GetExperimentFromContext(ctx)
+	tags, err := core.GetTemplateTags(ctx, nil)
 	if err != nil {
 		log.Error(err)
 		return err
 	}
-	ee := EnhancedExperiment{Experiment: exp}
-
-	log.Trace("got past enhanced experiment")
 
 	if t.With.Secret != nil {
-		secret, err := core.GetSecret(*t.With.Secret)
+		secret, err := core.GetSecret(ctx, *t.With.Secret)
 		if err != nil {
 			return err
 		}
-		ee.sec = secret
+		*tags = tags.WithSecret("secret", secret)
 	}
 
-	log.Trace("got past secret")
-
-	var templ *template.Template
-	if templ, err = template.New("templated script").Parse(*t.TaskMeta.Run); err == nil {
-		buf := bytes.Buffer{}
-		if err = templ.Execute(&buf, &ee); err == nil {
-			t.With.interpolatedRun = buf.String()
-			log.Trace("interpolated with enhanced experiment")
-			log.Trace(t.With.interpolatedRun)
-			return nil
-		}
-		log.Error("template execution error: ", err)
-		return errors.New("cannot interpolate string due to template execution error")
+	if t.With.interpolatedRun, err = tags.Interpolate(t.TaskMeta.Run); err != nil {
+		return err
 	}
-	log.Error("template creation error: ", err)
-	return errors.New("cannot interpolate string due to template creation error")
+	log.Trace(t.With.interpolatedRun)
+	return nil
 }
 
 // get the command
@@ -128,14 +97,32 @@ func (t *Task) getCommand(ctx context.Context) (*exec.Cmd, error) {
 	return cmd, nil
 }
 
+// Validate checks that the script is a valid template.
+func (t *Task) Validate(exp *core.Experiment) error {
+	return core.ValidateTemplate(*t.TaskMeta.Run)
+}
+
+// Plan writes the interpolated script to w, without running it.
+func (t *Task) Plan(ctx context.Context, w io.Writer) error {
+	if err := t.Interpolate(ctx); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "command: /bin/bash -c\nscript:\n%s\n", t.With.interpolatedRun)
+	return nil
+}
+
 // Run the command.
 func (t *Task) Run(ctx context.Context) error {
 	cmd, err := t.getCommand(ctx)
 	if err != nil {
 		return err
 	}
-	out, err := cmd.CombinedOutput()
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err = core.RunCommand(ctx, cmd)
 	log.Trace("Running task: " + cmd.String())
-	log.Trace("Got combined output: " + string(out))
+	log.Trace("Got combined output: " + out.String())
+	core.SetOutput(ctx, "output", out.String())
 	return err
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/iter8-tools/etc3/api/v2alpha2"
@@ -23,6 +24,7 @@ var log *logrus.Logger
 
 func init() {
 	log = core.GetLogger()
+	core.MustRegisterTask(TaskName, Make)
 }
 
 // Inputs is the object corresponding to the expcted inputs to the task
@@ -68,6 +70,18 @@ func (t *Task) Run(ctx context.Context) error {
 	return nil
 }
 
+// Plan writes the channel and message to w, without posting the message.
+func (t *Task) Plan(ctx context.Context, w io.Writer) error {
+	exp, err := core.GetExperimentFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "channel:", t.With.Channel)
+	fmt.Fprintln(w, "title:", Bold(string(exp.Spec.Strategy.TestingPattern)+" experiment on "+exp.Spec.Target))
+	fmt.Fprintf(w, "message:\n%s\n", SlackMessage(exp))
+	return nil
+}
+
 // Actual task runner
 func (t *Task) internalRun(ctx context.Context) error {
 	// Called to execute the Task
@@ -79,17 +93,18 @@ func (t *Task) internalRun(ctx context.Context) error {
 		return err
 	}
 	log.Trace("experiment", exp)
-	return t.postNotification(exp)
+	return t.postNotification(ctx, exp)
 }
 
-func (t *Task) postNotification(e *core.Experiment) error {
-	token := t.getToken()
+func (t *Task) postNotification(ctx context.Context, e *core.Experiment) error {
+	token := t.getToken(ctx)
 	if token == nil {
 		return errors.New("unable to find token")
 	}
-	log.Trace("token", t.getToken())
+	log.Trace("token", t.getToken(ctx))
 	api := slack.New(*token)
-	channelID, timestamp, err := api.PostMessage(
+	channelID, timestamp, err := api.PostMessageContext(
+		ctx,
 		t.With.Channel,
 		slack.MsgOptionBlocks(slack.NewSectionBlock(&slack.TextBlockObject{
 			Type: slack.MarkdownType,
@@ -111,6 +126,10 @@ func (t *Task) postNotification(e *core.Experiment) error {
 
 	log.Trace("channelID", channelID)
 	log.Trace("timestamp", timestamp)
+	if err == nil {
+		core.SetOutput(ctx, "channel", channelID)
+		core.SetOutput(ctx, "timestamp", timestamp)
+	}
 	return err
 }
 
@@ -197,7 +216,7 @@ const (
 	Space string = " "
 )
 
-func (t *Task) getToken() *string {
+func (t *Task) getToken(ctx context.Context) *string {
 	// get secret namespace and name
 	namespace := viper.GetViper().GetString("experiment_namespace")
 	var name string
@@ -210,7 +229,7 @@ func (t *Task) getToken() *string {
 		name = nn[1]
 	}
 
-	s, err := core.GetSecret(namespace + "/" + name)
+	s, err := core.GetSecret(ctx, namespace+"/"+name)
 	if err != nil {
 		log.Error(err)
 		return nil
@@ -35,7 +35,7 @@ var _ = Describe("GetSecretToken", func() {
 					Secret: "default/mysecret",
 				},
 			}
-			token := st.getToken()
+			token := st.getToken(context.Background())
 			Expect(*token).To(Equal("abc123"))
 
 			Expect(k8sClient.Delete(context.Background(), &secret)).To(Succeed())
@@ -64,7 +64,7 @@ var _ = Describe("GetSecretToken", func() {
 					Secret: "default/mysecret",
 				},
 			}
-			token := st.getToken()
+			token := st.getToken(context.Background())
 			Expect(token).To(BeNil())
 
 			Expect(k8sClient.Delete(context.Background(), &secret)).To(Succeed())